@@ -6,12 +6,25 @@ import (
 	"time"
 )
 
+const allocatedKey = "alloctd"
+const allocationTimeKey = "alloc_ms"
+
 type Allocator struct {
 	tracker *Tracker
 	blob    []*[]byte
 	total   int
 }
 
+// allocateAndTrack allocates a block and tracks how long it took,
+// slow allocations mean that the system has to reclaim memory or swap
+func (f *Allocator) allocateAndTrack(blockSizeInMb int) {
+	start := time.Now()
+	f.allocateBlock(blockSizeInMb)
+	f.total = f.total + blockSizeInMb
+	f.tracker.trackOne(allocationTimeKey, float64(time.Since(start))/float64(time.Millisecond))
+	f.tracker.trackOne(allocatedKey, f.total)
+}
+
 func (f *Allocator) allocateBlock(blockSizeInMb int) {
 	const bytesInMb int = 1024 * 1024
 	totalSize := blockSizeInMb * bytesInMb
@@ -27,22 +40,22 @@ func (f *Allocator) allocateBlock(blockSizeInMb int) {
 
 func (f *Allocator) initialize(t *Tracker, initialBlockSizeMb int) {
 	f.tracker = t
-	f.tracker.trackOne("alloctd", 0)
+	f.tracker.register(
+		Metric{Name: allocatedKey, Type: Gauge, Unit: unitMb, Help: "Total size of memory blocks allocated by the allocator", Export: "allocated_bytes"},
+		Metric{Name: allocationTimeKey, Type: Gauge, Unit: unitMilliseconds, Help: "Time spent on the last block allocation"},
+	)
+	f.tracker.trackOne(allocatedKey, 0)
 	if initialBlockSizeMb > 0 {
 		log.Printf("Pre-allocating initial block")
-		f.allocateBlock(initialBlockSizeMb)
+		f.allocateAndTrack(initialBlockSizeMb)
 		log.Printf("Allocated, size is %v Mb", initialBlockSizeMb)
-		f.total = initialBlockSizeMb
-		f.tracker.trackOne("alloctd", initialBlockSizeMb)
 	}
 }
 
 func (f *Allocator) startMemoryFilling(blockSizeInMb int, period time.Duration, limit int) {
 	ticker := time.NewTicker(period)
 	for f.total < limit || limit == 0 {
-		f.allocateBlock(blockSizeInMb)
-		f.total = f.total + blockSizeInMb
-		f.tracker.trackOne("alloctd", f.total)
+		f.allocateAndTrack(blockSizeInMb)
 		<-ticker.C
 	}
 	log.Printf("Allocated %v Mb, maximum limit is set to %v, stopping allocation process...", f.total, limit)
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math"
+	"path"
+	"time"
+)
+
+const cgroupEventRateKey = "cg_event_rate"
+
+// scopes of cgroup v2 memory events: 'memory.events' counts the events of the cgroup and its descendants,
+// 'memory.events.local' - only the cgroup's own ones
+var cgroupEventFiles = []struct {
+	scope string
+	file  string
+}{
+	{"hierarchy", "memory.events"},
+	{"local", "memory.events.local"},
+}
+
+var cgroupMemoryEvents = []string{"low", "high", "max", "oom", "oom_kill", "oom_group_kill"}
+
+// CgroupEventsObserver reports per-second rates of cgroup v2 memory events of the monitored cgroups.
+// A rising 'high' rate means that the workload is throttled by reclaim, which is an early pressure signal.
+type CgroupEventsObserver struct {
+	tracker    *Tracker
+	reader     Reader
+	enabled    bool
+	dirs       map[string]string
+	lastCounts map[string]map[string]float64
+	lastTimes  map[string]time.Time
+}
+
+func (o *CgroupEventsObserver) SetFlags() {
+	flag.BoolVar(&o.enabled, "cgroupEvents", false, "report per-second rates of cgroup v2 'memory.events' and 'memory.events.local' counters of the monitored cgroups")
+}
+
+func (o *CgroupEventsObserver) Initialize(t *Tracker, r Reader) {
+	o.tracker = t
+	o.reader = r
+	if !o.enabled {
+		return
+	}
+	mount, ok := cgroupV2Mount(r)
+	if !ok {
+		log.Print("cgroup v2 memory events won't be tracked: cgroup v2 is not mounted")
+		return
+	}
+	cgroups := monitoredCgroups
+	if len(cgroups) == 0 {
+		cgroup, err := selfCgroup(r, "")
+		if err != nil {
+			log.Print("cgroup v2 memory events won't be tracked: ", err)
+			return
+		}
+		cgroups = cgroupList{cgroup}
+	}
+
+	o.tracker.register(Metric{Name: cgroupEventRateKey, Type: Gauge, Unit: unitEventsPerSecond, Help: "Rate of the cgroup v2 memory event ('memory.events' for the hierarchy scope, 'memory.events.local' for the local one)", Labels: []string{"cgroup", "event", "scope"}})
+	o.dirs = make(map[string]string)
+	o.lastCounts = make(map[string]map[string]float64)
+	o.lastTimes = make(map[string]time.Time)
+	for _, cgroup := range cgroups {
+		o.dirs[cgroup] = path.Join(mount, cgroup)
+	}
+	o.process()
+}
+
+func (o *CgroupEventsObserver) TimerEvent() {
+	if o.dirs != nil {
+		o.process()
+	}
+}
+
+// eventRate returns the per-second rate of the counter, NaN if there is no previous value,
+// a counter reset (e.g. the cgroup is recreated) is counted from zero
+func eventRate(count float64, last float64, seconds float64) float64 {
+	if math.IsNaN(last) || seconds <= 0 {
+		return math.NaN()
+	}
+	if count < last {
+		last = 0
+	}
+	return (count - last) / seconds
+}
+
+// process calculates the rates over the time between the reads of the file,
+// so they don't depend on when the main loop iteration has started
+func (o *CgroupEventsObserver) process() {
+	for cgroup, dir := range o.dirs {
+		for _, f := range cgroupEventFiles {
+			filename := path.Join(dir, f.file)
+			counts, err := o.reader.getFloatKeyValuePairs(filename)
+			if err != nil {
+				// 'memory.events.local' is missing before Linux 5.2
+				counts = nil
+			}
+			now := o.reader.readTime(filename)
+			seconds := now.Sub(o.lastTimes[filename]).Seconds()
+			last, ok := o.lastCounts[filename]
+			for _, event := range cgroupMemoryEvents {
+				rate := math.NaN()
+				if count, found := counts[event]; found && ok {
+					previous, seen := last[event]
+					if !seen {
+						previous = math.NaN()
+					}
+					rate = eventRate(count, previous, seconds)
+				}
+				o.tracker.trackLabeled(cgroupEventRateKey, Labels{{"cgroup", cgroup}, {"event", event}, {"scope", f.scope}}, rate)
+			}
+			o.lastCounts[filename] = counts
+			o.lastTimes[filename] = now
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "math"
+import "os"
+import "path/filepath"
+import "strconv"
+import "testing"
+import "time"
+
+func TestEventRate(t *testing.T) {
+	if rate := eventRate(15, 5, 2); rate != 5 {
+		t.Errorf("Expected 5 events/s, got %v", rate)
+	}
+	if rate := eventRate(3, 5, 1); rate != 3 {
+		t.Errorf("Counter reset must be counted from zero, got %v", rate)
+	}
+	if rate := eventRate(3, math.NaN(), 1); !math.IsNaN(rate) {
+		t.Errorf("Expected NaN without the previous value, got %v", rate)
+	}
+}
+
+func TestCgroupEventsObserver(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "fs/cgroup/workload")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "fs/cgroup/cgroup.controllers"), []byte("cpu memory\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents := func(high int) {
+		content := []byte("low 0\nhigh " + strconv.Itoa(high) + "\nmax 0\noom 0\noom_kill 0\noom_group_kill 0\n")
+		if err := os.WriteFile(filepath.Join(dir, "memory.events"), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	monitoredCgroups = cgroupList{"/workload"}
+	defer func() { monitoredCgroups = nil }()
+	start := time.Unix(1000, 0)
+	now := start
+	tr := Tracker{}
+	snapshot := newSnapshot(func() time.Time { return now })
+	o := CgroupEventsObserver{enabled: true}
+	writeEvents(2)
+	o.Initialize(&tr, FileReader{sysRoot: root, snapshot: snapshot})
+
+	rate := func(event string, scope string) float64 {
+		return snapshotValues(&tr)["cg_event_rate{cgroup=/workload,event="+event+",scope="+scope+"}"]
+	}
+	if value := rate("high", "hierarchy"); !math.IsNaN(value) {
+		t.Errorf("Expected NaN rate on the first update, got %v", value)
+	}
+
+	// the rate is calculated over the time between the reads, not the main loop iterations
+	writeEvents(8)
+	snapshot.reset()
+	now = start.Add(2 * time.Second)
+	o.TimerEvent()
+	if value := rate("high", "hierarchy"); value != 3.0 {
+		t.Errorf("Expected 3 'high' events/s, got %v", value)
+	}
+	if value := rate("max", "hierarchy"); value != 0.0 {
+		t.Errorf("Expected no 'max' events, got %v", value)
+	}
+	// 'memory.events.local' is missing
+	if value := rate("high", "local"); !math.IsNaN(value) {
+		t.Errorf("Expected NaN rate for the missing file, got %v", value)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+const selfCgroupFile = "/proc/self/cgroup"
+
+// cgroupList is a repeatable '-cgroup' command-line option, paths are relative to the hierarchy root
+type cgroupList []string
+
+func (l *cgroupList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *cgroupList) Set(value string) error {
+	if !strings.HasPrefix(value, "/") {
+		return fmt.Errorf("Cgroup path must start with '/', got '%s'", value)
+	}
+	*l = append(*l, path.Clean(value))
+	return nil
+}
+
+// monitoredCgroups are the cgroups, which are selected with '-cgroup' for all the cgroup-aware observers
+var monitoredCgroups cgroupList
+
+// selfCgroup returns the path of the process's cgroup, which is listed in '/proc/self/cgroup'
+// as 'hierarchy-ID:controller-list:cgroup-path', empty controller is for cgroup v2 hierarchy
+func selfCgroup(r Reader, controller string) (string, error) {
+	text, err := r.getTextWhole(selfCgroupFile)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if controller == "" && fields[0] == "0" && fields[1] == "" {
+			return fields[2], nil
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if controller != "" && c == controller {
+				return fields[2], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Cgroup of the process was not found in '%s'", selfCgroupFile)
+}
+
+// cgroupV2Mount returns the mount point of cgroup v2 hierarchy, either unified or hybrid one
+func cgroupV2Mount(r Reader) (string, bool) {
+	for _, mount := range []string{cgroupRoot, path.Join(cgroupRoot, "unified")} {
+		if _, err := r.getTextWhole(path.Join(mount, "cgroup.controllers")); err == nil {
+			return mount, true
+		}
+	}
+	return "", false
+}
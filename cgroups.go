@@ -6,151 +6,182 @@ package main
 import "C"
 
 import (
-	"encoding/binary"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"syscall"
+	"path"
+	"strings"
+	"sync"
+	"time"
 )
 
-const eventControlPath = "/sys/fs/cgroup/memory/cgroup.event_control"
-const pressureLevelPath = "/sys/fs/cgroup/memory/memory.pressure_level"
+const cgroupV1MemoryRoot = "/sys/fs/cgroup/memory"
+const eventControlPath = cgroupV1MemoryRoot + "/cgroup.event_control"
+const pressureLevelPath = cgroupV1MemoryRoot + "/memory.pressure_level"
+const cgroupsPressureKey = "cgroups"
 
+// vmpressure events propagation modes, see Documentation/admin-guide/cgroup-v1/memory.rst
+const (
+	pressureModeDefault   = "default"
+	pressureModeHierarchy = "hierarchy"
+	pressureModeLocal     = "local"
+)
+
+const (
+	cgroupsAuto = "auto"
+	cgroupsV1   = "v1"
+	cgroupsV2   = "v2"
+)
+
+// CgroupsObserver reports memory pressure of a cgroup as a bit mask ('critical - medium - low').
+// With cgroup v1 it subscribes to vmpressure events of 'memory.pressure_level',
+// with cgroup v2 it watches 'memory.events' for 'high', 'max' and 'oom' events.
 type CgroupsObserver struct {
-	tracker         *Tracker
-	notifyChan      chan bool
-	lowEventFd      int
-	mediumEventFd   int
-	criticalEventFd int
-	lowLevel        bool
-	mediumLevel     bool
-	criticalLevel   bool
-	oldPressure     int
+	tracker      *Tracker
+	reader       Reader
+	notifyChan   chan bool
+	version      string
+	pressureMode string
+	eventTimeout time.Duration
+	usageLimits  thresholdList
+	memswLimits  thresholdList
+	mtx          sync.Mutex
+	pressure     map[string]int
 }
 
-func (o *CgroupsObserver) createFd() (int, error) {
-	fd, err := C.eventfd(0, C.EFD_CLOEXEC)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return int(fd), err
+func (o *CgroupsObserver) SetFlags() {
+	flag.StringVar(&o.version, "cgroupsVersion", cgroupsAuto, "cgroup hierarchy to use: 'v1', 'v2' or 'auto' to detect the mounted one")
+	flag.StringVar(&o.pressureMode, "cgroupsPressureMode", pressureModeDefault, "cgroup v1 only: vmpressure events propagation mode: 'default' (an event goes to the closest cgroup with a listener), 'hierarchy' (every cgroup gets the events of its descendants) or 'local' (only the cgroup's own events)")
+	flag.Var(&o.usageLimits, "cgroupUsageThreshold", "cgroup v1 only: 'memory.usage_in_bytes' threshold to be notified about, in percents of the limit (e.g. '80%') or in bytes (e.g. '512M'), can be repeated")
+	flag.Var(&o.memswLimits, "cgroupMemswThreshold", "cgroup v1 only: 'memory.memsw.usage_in_bytes' (memory and swap) threshold to be notified about, in percents of the limit or in bytes, can be repeated")
+	flag.DurationVar(&o.eventTimeout, "cgroupsEventTimeout", 10*time.Second, "time the pressure bit stays set after the last vmpressure event (cgroup v1) or 'memory.events' event (cgroup v2)")
 }
 
-func (o *CgroupsObserver) enableNotification(eventfd int, level string) error {
-
-	fileEventControl, err := os.OpenFile(eventControlPath, os.O_RDWR, 0755)
-	if err != nil {
-		return err
+// detectCgroupsVersion prefers cgroup v1 memory controller, if it's mounted, because it has vmpressure events
+func detectCgroupsVersion(r Reader) (string, error) {
+	if _, err := os.Stat(r.resolvePath(pressureLevelPath)); err == nil {
+		return cgroupsV1, nil
 	}
-	defer fileEventControl.Close()
-
-	filePressure, err := os.Open(pressureLevelPath)
-	if err != nil {
-		return err
+	if mount, ok := cgroupV2Mount(r); ok {
+		controllers, _ := r.getTextWhole(path.Join(mount, "cgroup.controllers"))
+		for _, c := range strings.Fields(controllers) {
+			if c == "memory" {
+				return cgroupsV2, nil
+			}
+		}
 	}
-	defer filePressure.Close()
-
-	command := fmt.Sprintf("%d %d %s", eventfd, filePressure.Fd(), level)
-	_, err = fileEventControl.WriteString(command)
-	return err
-}
-
-func (o *CgroupsObserver) SetFlags() {
-
+	return "", fmt.Errorf("Neither cgroup v1 nor cgroup v2 memory controller is mounted")
 }
 
 func (o *CgroupsObserver) Initialize(t *Tracker, r Reader, c chan bool) {
 	o.tracker = t
+	o.reader = r
 	o.notifyChan = c
-	o.oldPressure = 0xFF
-	var err error
-	atLeastOne := false
+	o.pressure = make(map[string]int)
+	o.tracker.register(Metric{Name: cgroupsPressureKey, Type: Bitmask, Help: "cgroups memory pressure bit mask (critical - medium - low)", Labels: []string{"cgroup"}, Unlabeled: true, Export: "cgroups_pressure_state"})
 
-	o.lowEventFd, err = o.createFd()
-	if err == nil {
-		err = o.enableNotification(o.lowEventFd, "low")
-	}
-	if err != nil {
-		log.Print(err)
-	} else {
-		atLeastOne = true
-		go o.startCheckingPressure(o.lowEventFd, &o.lowLevel)
+	version := o.version
+	if version == cgroupsAuto {
+		var err error
+		if version, err = detectCgroupsVersion(r); err != nil {
+			log.Print(err)
+			return
+		}
 	}
 
-	o.mediumEventFd, err = o.createFd()
-	if err == nil {
-		err = o.enableNotification(o.mediumEventFd, "medium")
-	}
-	if err != nil {
-		log.Print(err)
-	} else {
-		atLeastOne = true
-		go o.startCheckingPressure(o.mediumEventFd, &o.mediumLevel)
+	switch o.pressureMode {
+	case pressureModeDefault, pressureModeHierarchy, pressureModeLocal:
+	default:
+		log.Fatalf("Unknown vmpressure propagation mode '%s'", o.pressureMode)
 	}
 
-	o.criticalEventFd, err = o.createFd()
-	if err == nil {
-		err = o.enableNotification(o.criticalEventFd, "critical")
-	}
-	if err != nil {
-		log.Print(err)
-	} else {
-		atLeastOne = true
-		go o.startCheckingPressure(o.criticalEventFd, &o.criticalLevel)
+	switch version {
+	case cgroupsV1:
+		cgroups := monitoredCgroups
+		if len(cgroups) == 0 {
+			cgroups = cgroupList{"/"}
+		}
+		if len(o.usageLimits) > 0 || len(o.memswLimits) > 0 {
+			o.registerThresholdMetrics()
+		}
+		o.registerOomControlMetrics()
+		for _, cgroup := range cgroups {
+			w := &cgroupV1Pressure{observer: o, cgroup: cgroup, mode: o.pressureMode}
+			w.start()
+			o.startThresholds(cgroup)
+			oom := &cgroupV1Oom{observer: o, cgroup: cgroup}
+			oom.start()
+		}
+	case cgroupsV2:
+		mount, _ := cgroupV2Mount(r)
+		cgroups := monitoredCgroups
+		if len(cgroups) == 0 {
+			cgroup, err := selfCgroup(r, "")
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			cgroups = cgroupList{cgroup}
+		}
+		o.registerV2Metrics()
+		for _, cgroup := range cgroups {
+			w := newCgroupV2Memory(o, mount, cgroup)
+			w.start()
+		}
+	default:
+		log.Fatalf("Unknown cgroup version '%s'", version)
 	}
+}
 
-	if atLeastOne == true {
-		o.reportPressureIfChanged()
+// report updates the pressure bit mask of the cgroup and wakes up the main loop if it has changed
+func (o *CgroupsObserver) report(cgroup string, pressure int) {
+	o.mtx.Lock()
+	old, ok := o.pressure[cgroup]
+	o.pressure[cgroup] = pressure
+	o.mtx.Unlock()
+	if ok && old == pressure {
+		return
 	}
+
+	o.track(cgroupsPressureKey, cgroup, pressure)
+	o.notify()
 }
 
-func (o *CgroupsObserver) Close() error {
-	syscall.Close(o.lowEventFd)
-	syscall.Close(o.mediumEventFd)
-	syscall.Close(o.criticalEventFd)
-	return nil
+func (o *CgroupsObserver) notify() {
+	// non-nlocking notification sending
+	select {
+	case o.notifyChan <- true:
+	default:
+	}
 }
 
-func (o *CgroupsObserver) startCheckingPressure(eventfd int, target *bool) {
-	buf := make([]byte, 8)
-	for {
-		n, err := syscall.Read(eventfd, buf[:])
-		if err != nil {
-			log.Print(err)
-		}
-		val, n := binary.Uvarint(buf)
-		if n > 0 {
-			if val > 0 {
-				*target = true
-			} else {
-				*target = false
-			}
-		}
-		o.reportPressureIfChanged()
+// track puts a value of the cgroup into the tracker: the series of the default cgroup
+// are unlabeled, the ones of the cgroups given with '-cgroup' are labeled with the path
+func (o *CgroupsObserver) track(key string, cgroup string, value interface{}) {
+	if len(monitoredCgroups) == 0 {
+		o.tracker.trackOne(key, value)
+		return
 	}
+	o.tracker.trackLabeled(key, Labels{{"cgroup", cgroup}}, value)
 }
 
-func (o *CgroupsObserver) reportPressureIfChanged() {
-	const pressureKey = "cgroups"
-	newPressure := 0
-	if o.criticalLevel {
-		newPressure |= 1 << 2
+// pressureMask packs 'critical - medium - low' levels into a bit mask
+func pressureMask(low, medium, critical bool) int {
+	mask := 0
+	if critical {
+		mask |= 1 << 2
 	}
-	if o.mediumLevel {
-		newPressure |= 1 << 1
+	if medium {
+		mask |= 1 << 1
 	}
-	if o.lowLevel {
-		newPressure |= 1 << 0
+	if low {
+		mask |= 1 << 0
 	}
+	return mask
+}
 
-	if newPressure != o.oldPressure {
-		o.oldPressure = newPressure
-		o.tracker.trackOne(pressureKey, newPressure)
-
-		// non-nlocking notification sending
-		select {
-		case o.notifyChan <- true:
-		default:
-		}
-	}
+func createEventFd() (int, error) {
+	fd, err := C.eventfd(0, C.EFD_CLOEXEC)
+	return int(fd), err
 }
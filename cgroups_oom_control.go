@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	cgroupOomEventsKey      = "cg_oom_events"
+	cgroupUnderOomKey       = "cg_under_oom"
+	cgroupOomKillDisableKey = "cg_oom_kill_disable"
+)
+
+// cgroupOomPollInterval is how often 'memory.oom_control' is read while the cgroup is under OOM,
+// there is no notification when it's over
+const cgroupOomPollInterval = time.Second
+
+func (o *CgroupsObserver) registerOomControlMetrics() {
+	o.tracker.register(
+		Metric{Name: cgroupOomEventsKey, Type: Counter, Help: "Number of OOM notifications of the cgroup v1 'memory.oom_control' since the start", Labels: []string{"cgroup"}},
+		Metric{Name: cgroupUnderOomKey, Type: Gauge, Help: "1 if the cgroup is under OOM now ('under_oom' of 'memory.oom_control'), 0 otherwise", Labels: []string{"cgroup"}},
+		Metric{Name: cgroupOomKillDisableKey, Type: Gauge, Help: "1 if the kernel OOM killer is disabled for the cgroup ('oom_kill_disable' of 'memory.oom_control'), 0 otherwise", Labels: []string{"cgroup"}},
+	)
+}
+
+// cgroupV1Oom subscribes to OOM notifications of the cgroup v1 'memory.oom_control'
+type cgroupV1Oom struct {
+	observer *CgroupsObserver
+	cgroup   string
+	mtx      sync.Mutex
+	events   int64
+	underOom int64
+}
+
+func (w *cgroupV1Oom) file() string {
+	return path.Join(cgroupV1MemoryRoot, w.cgroup, "memory.oom_control")
+}
+
+func (w *cgroupV1Oom) start() {
+	eventfd, err := createEventFd()
+	if err == nil {
+		err = registerCgroupEvent(w.observer.reader, path.Dir(w.file()), path.Base(w.file()), eventfd, "")
+	}
+	if err != nil {
+		log.Printf("Failed to subscribe to OOM notifications of '%s' cgroup: %v", w.cgroup, err)
+		return
+	}
+	w.update(0)
+	go w.startWaiting(eventfd)
+}
+
+func (w *cgroupV1Oom) startWaiting(eventfd int) {
+	defer syscall.Close(eventfd)
+	for {
+		n, err := waitEventFd(eventfd)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		for underOom := w.update(n); underOom; underOom = w.update(0) {
+			w.observer.notify()
+			time.Sleep(cgroupOomPollInterval)
+		}
+		w.observer.notify()
+	}
+}
+
+// update adds the new notifications and reads the actual state, it returns true, if the cgroup is under OOM
+func (w *cgroupV1Oom) update(newEvents uint64) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	labels := Labels{{"cgroup", w.cgroup}}
+	w.events += int64(newEvents)
+	if newEvents > 0 {
+		log.Printf("'%s' cgroup is out of memory, %d OOM notification(s) in total", w.cgroup, w.events)
+	}
+	w.observer.tracker.trackLabeled(cgroupOomEventsKey, labels, w.events)
+
+	state, err := readCgroupEvents(w.observer.reader, w.file())
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	if state["under_oom"] != w.underOom {
+		log.Printf("'under_oom' of '%s' cgroup has changed to %d", w.cgroup, state["under_oom"])
+	}
+	w.underOom = state["under_oom"]
+	w.observer.tracker.trackLabeled(cgroupUnderOomKey, labels, state["under_oom"])
+	w.observer.tracker.trackLabeled(cgroupOomKillDisableKey, labels, state["oom_kill_disable"])
+	return w.underOom > 0
+}
@@ -0,0 +1,161 @@
+package main
+
+import "math"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+
+const testSysRoot = "./test_samples/sys"
+
+func TestDetectCgroupsVersion(t *testing.T) {
+	r := FileReader{procRoot: testProcRoot, sysRoot: testSysRoot}
+	version, err := detectCgroupsVersion(r)
+	if err != nil || version != cgroupsV2 {
+		t.Errorf("Expected cgroup v2, got '%s' (%v)", version, err)
+	}
+
+	r = FileReader{sysRoot: t.TempDir()}
+	if _, err := detectCgroupsVersion(r); err == nil {
+		t.Errorf("Expected an error without cgroup hierarchies")
+	}
+}
+
+func TestCgroupV2Megabytes(t *testing.T) {
+	if value := cgroupV2Megabytes("2147483648"); value != 2048 {
+		t.Errorf("Expected 2048 Mb, got %v", value)
+	}
+	if value := cgroupV2Megabytes("max"); !math.IsNaN(value) {
+		t.Errorf("Expected NaN for no limit, got %v", value)
+	}
+}
+
+func TestCgroupV2Pressure(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "fs/cgroup/workload")
+	os.MkdirAll(dir, 0755)
+	writeEvents := func(events string) {
+		if err := os.WriteFile(filepath.Join(dir, "memory.events"), []byte(events), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEvents("low 0\nhigh 5\nmax 0\noom 0\noom_kill 0\n")
+	os.WriteFile(filepath.Join(dir, "memory.current"), []byte("104857600\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "memory.max"), []byte("max\n"), 0644)
+
+	tr := Tracker{}
+	o := CgroupsObserver{tracker: &tr, reader: FileReader{sysRoot: root}, notifyChan: make(chan bool, 1), eventTimeout: 10 * time.Second, pressure: make(map[string]int)}
+	o.registerV2Metrics()
+	w := newCgroupV2Memory(&o, cgroupRoot, "/workload")
+
+	start := time.Unix(1568822281, 0)
+	steps := []struct {
+		events   string
+		elapsed  time.Duration
+		expected int
+	}{
+		{"low 0\nhigh 5\nmax 0\noom 0\noom_kill 0\n", 0, 0},
+		{"low 0\nhigh 9\nmax 0\noom 0\noom_kill 0\n", time.Second, 1},
+		{"low 0\nhigh 9\nmax 2\noom 1\noom_kill 1\n", 5 * time.Second, 7},
+		{"low 0\nhigh 9\nmax 2\noom 1\noom_kill 1\n", 12 * time.Second, 6},
+		{"low 0\nhigh 9\nmax 2\noom 1\noom_kill 1\n", 20 * time.Second, 0},
+	}
+	for i, step := range steps {
+		writeEvents(step.events)
+		w.update(start.Add(step.elapsed))
+		if o.pressure["/workload"] != step.expected {
+			t.Errorf("Step %d: expected pressure %d, got %d", i, step.expected, o.pressure["/workload"])
+		}
+	}
+
+	values := snapshotValues(&tr)
+	if values["cg_mem_current"] != 100.0 {
+		t.Errorf("Expected 100 Mb usage, got %v", values["cg_mem_current"])
+	}
+	if value, ok := values["cg_mem_max"]; !ok || !math.IsNaN(value) {
+		t.Errorf("Expected NaN for no limit, got %v", values["cg_mem_max"])
+	}
+}
+
+func TestCgroupV1PressureTimeout(t *testing.T) {
+	o := CgroupsObserver{tracker: &Tracker{}, notifyChan: make(chan bool, 1), eventTimeout: 10 * time.Second, pressure: make(map[string]int)}
+	w := cgroupV1Pressure{observer: &o, cgroup: "/workload"}
+
+	start := time.Unix(1568822281, 0)
+	w.observe(0, start)
+	w.observe(2, start.Add(5*time.Second))
+	defer w.expiry.Stop()
+	steps := []struct {
+		elapsed  time.Duration
+		expected int
+	}{
+		{5 * time.Second, 5},
+		{12 * time.Second, 4},
+		{20 * time.Second, 0},
+	}
+	for i, step := range steps {
+		w.update(start.Add(step.elapsed))
+		if o.pressure["/workload"] != step.expected {
+			t.Errorf("Step %d: expected pressure %d, got %d", i, step.expected, o.pressure["/workload"])
+		}
+	}
+}
+
+func TestCgroupList(t *testing.T) {
+	var l cgroupList
+	if err := l.Set("/workload/batch/"); err != nil || l[0] != "/workload/batch" {
+		t.Errorf("Expected '/workload/batch', got %v (%v)", l, err)
+	}
+	if err := l.Set("workload"); err == nil {
+		t.Errorf("Relative cgroup path must be rejected")
+	}
+}
+
+func TestCgroupsSeries(t *testing.T) {
+	tr := Tracker{}
+	o := CgroupsObserver{version: cgroupsAuto, pressureMode: pressureModeDefault}
+	o.Initialize(&tr, FileReader{sysRoot: t.TempDir()}, make(chan bool, 1))
+	o.track(cgroupsPressureKey, "/", 1)
+
+	defer func(cgroups cgroupList) { monitoredCgroups = cgroups }(monitoredCgroups)
+	monitoredCgroups = cgroupList{"/workload"}
+	o.track(cgroupsPressureKey, "/workload", 3)
+
+	values := snapshotValues(&tr)
+	if len(values) != 2 || values["cgroups"] != 1 || values["cgroups{cgroup=/workload}"] != 3 {
+		t.Errorf("Expected unlabeled default cgroup and labeled '-cgroup' one, got %v", values)
+	}
+}
+
+func TestUsageThreshold(t *testing.T) {
+	const limit = 1024 * 1024 * 1024
+	cases := []struct {
+		spec     string
+		limit    int64
+		expected int64
+		ok       bool
+	}{
+		{"80%", limit, limit * 4 / 5, true},
+		{"80%", cgroupV1Unlimited + 4096, 0, false},
+		{"512M", cgroupV1Unlimited + 4096, 512 * 1024 * 1024, true},
+		{"1G", limit, limit, true},
+		{"4096", limit, 4096, true},
+	}
+	for _, c := range cases {
+		threshold, err := parseUsageThreshold(c.spec)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", c.spec, err)
+			continue
+		}
+		bytes, ok := threshold.inBytes(c.limit)
+		if bytes != c.expected || ok != c.ok {
+			t.Errorf("'%s' of %d: expected %d (%v), got %d (%v)", c.spec, c.limit, c.expected, c.ok, bytes, ok)
+		}
+	}
+
+	for _, spec := range []string{"0%", "120%", "-5", "lots", ""} {
+		if _, err := parseUsageThreshold(spec); err == nil {
+			t.Errorf("Threshold '%s' must be rejected", spec)
+		}
+	}
+}
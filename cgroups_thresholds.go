@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const cgroupUsageOverKey = "cg_usage_over"
+
+const (
+	usageCounterMemory = "memory"
+	usageCounterMemsw  = "memsw"
+)
+
+// cgroup v1 reports this huge value as the limit, if there is no limit
+const cgroupV1Unlimited = int64(1) << 62
+
+// usageThreshold is either a percentage of the cgroup limit or an absolute value in bytes
+type usageThreshold struct {
+	spec    string
+	percent float64
+	bytes   int64
+}
+
+// parseUsageThreshold parses '80%', '536870912' or '512M' (K, M and G suffixes are supported)
+func parseUsageThreshold(spec string) (usageThreshold, error) {
+	result := usageThreshold{spec: spec}
+	if strings.HasSuffix(spec, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return result, fmt.Errorf("Threshold percentage must be in (0, 100], got '%s'", spec)
+		}
+		result.percent = percent
+		return result, nil
+	}
+
+	number := spec
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if strings.HasSuffix(spec, suffix) {
+			number = strings.TrimSuffix(spec, suffix)
+			multiplier = int64(1) << (10 * uint(i+1))
+		}
+	}
+	value, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || value <= 0 {
+		return result, fmt.Errorf("Threshold must be a positive number of bytes or a percentage, got '%s'", spec)
+	}
+	result.bytes = value * multiplier
+	return result, nil
+}
+
+// inBytes converts the threshold to bytes for the limit, it returns false for a percentage without the limit
+func (t usageThreshold) inBytes(limit int64) (int64, bool) {
+	if t.percent == 0 {
+		return t.bytes, true
+	}
+	if limit <= 0 || limit >= cgroupV1Unlimited {
+		return 0, false
+	}
+	return int64(math.Round(float64(limit) * t.percent / 100)), true
+}
+
+// thresholdList is a repeatable threshold command-line option
+type thresholdList []usageThreshold
+
+func (l *thresholdList) String() string {
+	var specs []string
+	for _, t := range *l {
+		specs = append(specs, t.spec)
+	}
+	return strings.Join(specs, ",")
+}
+
+func (l *thresholdList) Set(value string) error {
+	t, err := parseUsageThreshold(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, t)
+	return nil
+}
+
+func (o *CgroupsObserver) registerThresholdMetrics() {
+	o.tracker.register(Metric{Name: cgroupUsageOverKey, Type: Gauge, Help: "1 if the cgroup v1 memory (or memory and swap) usage is above the threshold, 0 otherwise", Labels: []string{"cgroup", "counter", "threshold"}})
+}
+
+func (o *CgroupsObserver) startThresholds(cgroup string) {
+	for _, u := range []*cgroupV1Usage{
+		{observer: o, cgroup: cgroup, counter: usageCounterMemory, thresholds: o.usageLimits},
+		{observer: o, cgroup: cgroup, counter: usageCounterMemsw, thresholds: o.memswLimits},
+	} {
+		if len(u.thresholds) > 0 {
+			u.start()
+		}
+	}
+}
+
+// cgroupV1Usage gets eventfd notifications when the cgroup v1 usage crosses the thresholds in any direction
+type cgroupV1Usage struct {
+	observer   *CgroupsObserver
+	cgroup     string
+	counter    string
+	thresholds []usageThreshold
+	mtx        sync.Mutex
+	bytes      map[string]int64
+	over       map[string]bool
+}
+
+func (u *cgroupV1Usage) file(name string) string {
+	prefix := "memory."
+	if u.counter == usageCounterMemsw {
+		prefix = "memory.memsw."
+	}
+	return path.Join(cgroupV1MemoryRoot, u.cgroup, prefix+name)
+}
+
+func (u *cgroupV1Usage) readBytes(name string) (int64, error) {
+	text, err := readCgroupFile(u.observer.reader, u.file(name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+func (u *cgroupV1Usage) start() {
+	u.bytes = make(map[string]int64)
+	u.over = make(map[string]bool)
+	limit, err := u.readBytes("limit_in_bytes")
+	if err != nil {
+		log.Printf("Failed to read '%s' limit of '%s' cgroup: %v", u.counter, u.cgroup, err)
+		return
+	}
+
+	dir := path.Join(cgroupV1MemoryRoot, u.cgroup)
+	for _, t := range u.thresholds {
+		bytes, ok := t.inBytes(limit)
+		if !ok {
+			log.Printf("'%s' threshold of '%s' cgroup is skipped, there is no '%s' limit", t.spec, u.cgroup, u.counter)
+			continue
+		}
+		eventfd, err := createEventFd()
+		if err == nil {
+			err = registerCgroupEvent(u.observer.reader, dir, path.Base(u.file("usage_in_bytes")), eventfd, strconv.FormatInt(bytes, 10))
+		}
+		if err != nil {
+			log.Printf("Failed to set '%s' threshold of '%s' cgroup: %v", t.spec, u.cgroup, err)
+			continue
+		}
+		u.bytes[t.spec] = bytes
+		go u.startWaiting(eventfd)
+	}
+	u.update()
+}
+
+func (u *cgroupV1Usage) startWaiting(eventfd int) {
+	defer syscall.Close(eventfd)
+	for {
+		if _, err := waitEventFd(eventfd); err != nil {
+			log.Print(err)
+			return
+		}
+		u.update()
+		u.observer.notify()
+	}
+}
+
+// update compares the actual usage with all the thresholds, a notification says only that one of them was crossed
+func (u *cgroupV1Usage) update() {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	usage, err := u.readBytes("usage_in_bytes")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for spec, bytes := range u.bytes {
+		over := usage >= bytes
+		if over != u.over[spec] {
+			log.Printf("'%s' usage of '%s' cgroup has crossed '%s' threshold: %d Mb", u.counter, u.cgroup, spec, usage/1024/1024)
+		}
+		u.over[spec] = over
+		value := 0
+		if over {
+			value = 1
+		}
+		u.observer.tracker.trackLabeled(cgroupUsageOverKey, Labels{{"cgroup", u.cgroup}, {"counter", u.counter}, {"threshold", spec}}, value)
+	}
+}
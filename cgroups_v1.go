@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// registerCgroupEvent subscribes the eventfd to the events of the file in cgroup v1 directory
+// with the standard 'cgroup.event_control' mechanism, args depend on the file
+func registerCgroupEvent(r Reader, dir string, file string, eventfd int, args string) error {
+	fileEventControl, err := os.OpenFile(r.resolvePath(path.Join(dir, "cgroup.event_control")), os.O_RDWR, 0755)
+	if err != nil {
+		return err
+	}
+	defer fileEventControl.Close()
+
+	fileTarget, err := os.Open(r.resolvePath(path.Join(dir, file)))
+	if err != nil {
+		return err
+	}
+	defer fileTarget.Close()
+
+	command := fmt.Sprintf("%d %d", eventfd, fileTarget.Fd())
+	if args != "" {
+		command += " " + args
+	}
+	_, err = fileEventControl.WriteString(command)
+	return err
+}
+
+// waitEventFd blocks until the event arrives, it returns the number of events since the previous call
+func waitEventFd(eventfd int) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := syscall.Read(eventfd, buf); err != nil {
+		return 0, err
+	}
+	return binary.NativeEndian.Uint64(buf), nil
+}
+
+// cgroupV1Pressure subscribes to vmpressure events of the cgroup v1 'memory.pressure_level',
+// a level is reported until there are no its events for the event timeout, the same way as with cgroup v2
+type cgroupV1Pressure struct {
+	observer   *CgroupsObserver
+	cgroup     string
+	mode       string
+	mtx        sync.Mutex
+	lastLevels [3]time.Time
+	expiry     *time.Timer
+}
+
+var pressureLevels = []string{"low", "medium", "critical"}
+
+func (w *cgroupV1Pressure) start() {
+	dir := path.Join(cgroupV1MemoryRoot, w.cgroup)
+	atLeastOne := false
+	for i, level := range pressureLevels {
+		args := level
+		if w.mode != pressureModeDefault {
+			args += "," + w.mode
+		}
+		eventfd, err := createEventFd()
+		if err == nil {
+			err = registerCgroupEvent(w.observer.reader, dir, "memory.pressure_level", eventfd, args)
+		}
+		if err != nil {
+			log.Printf("Failed to subscribe to '%s' pressure of '%s' cgroup: %v", level, w.cgroup, err)
+			continue
+		}
+		atLeastOne = true
+		go w.startCheckingPressure(eventfd, i)
+	}
+
+	if atLeastOne {
+		w.update(time.Now())
+	}
+}
+
+func (w *cgroupV1Pressure) startCheckingPressure(eventfd int, level int) {
+	defer syscall.Close(eventfd)
+	for {
+		if _, err := waitEventFd(eventfd); err != nil {
+			log.Print(err)
+			return
+		}
+		w.observe(level, time.Now())
+	}
+}
+
+func (w *cgroupV1Pressure) observe(level int, now time.Time) {
+	w.mtx.Lock()
+	w.lastLevels[level] = now
+	w.mtx.Unlock()
+	w.update(now)
+}
+
+// update reports the levels, which had events within the timeout,
+// and schedules the next update to the time the first of them expires
+func (w *cgroupV1Pressure) update(now time.Time) {
+	w.mtx.Lock()
+	var active [3]bool
+	var next time.Duration
+	for level, last := range w.lastLevels {
+		left := w.observer.eventTimeout - now.Sub(last)
+		active[level] = !last.IsZero() && left > 0
+		if active[level] && (next == 0 || left < next) {
+			next = left
+		}
+	}
+	if next > 0 {
+		if w.expiry == nil {
+			w.expiry = time.AfterFunc(next, func() { w.update(time.Now()) })
+		} else {
+			w.expiry.Reset(next)
+		}
+	}
+	w.mtx.Unlock()
+
+	w.observer.report(w.cgroup, pressureMask(active[0], active[1], active[2]))
+}
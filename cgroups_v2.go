@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	cgroupMemCurrentKey  = "cg_mem_current"
+	cgroupMemMaxKey      = "cg_mem_max"
+	cgroupMemHighKey     = "cg_mem_high"
+	cgroupSwapCurrentKey = "cg_swap_current"
+)
+
+// cgroupV2StatsInterval is how often memory usage and limits are read when there are no events
+const cgroupV2StatsInterval = time.Second
+
+func (o *CgroupsObserver) registerV2Metrics() {
+	o.tracker.register(
+		Metric{Name: cgroupMemCurrentKey, Type: Gauge, Unit: unitMb, Help: "Memory usage of the cgroup ('memory.current')", Labels: []string{"cgroup"}, Unlabeled: true},
+		Metric{Name: cgroupMemMaxKey, Type: Gauge, Unit: unitMb, Help: "Memory hard limit of the cgroup ('memory.max'), NaN if there is no limit", Labels: []string{"cgroup"}, Unlabeled: true},
+		Metric{Name: cgroupMemHighKey, Type: Gauge, Unit: unitMb, Help: "Memory throttling limit of the cgroup ('memory.high'), NaN if there is no limit", Labels: []string{"cgroup"}, Unlabeled: true},
+		Metric{Name: cgroupSwapCurrentKey, Type: Gauge, Unit: unitMb, Help: "Swap usage of the cgroup ('memory.swap.current')", Labels: []string{"cgroup"}, Unlabeled: true},
+	)
+}
+
+// readCgroupFile reads the file directly, bypassing the snapshot: active observers read
+// the files at any time, not once per main loop iteration
+func readCgroupFile(r Reader, filename string) (string, error) {
+	content, err := ioutil.ReadFile(r.resolvePath(filename))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// cgroupV2Megabytes converts cgroup v2 memory value in bytes to megabytes, 'max' (no limit) is NaN
+func cgroupV2Megabytes(text string) float64 {
+	if text == "max" {
+		return math.NaN()
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return float64(value) / 1024 / 1024
+}
+
+// readCgroupEvents parses flat keyed files like 'memory.events'
+func readCgroupEvents(r Reader, filename string) (map[string]int64, error) {
+	text, err := readCgroupFile(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseKeys([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, len(keys))
+	for k, values := range keys {
+		if value, err := strconv.ParseInt(values[0], 10, 64); err == nil {
+			result[k] = value
+		}
+	}
+	return result, nil
+}
+
+// cgroupV2Memory watches cgroup v2 'memory.events' with inotify and maps the events to pressure levels:
+// 'high' (the cgroup is throttled) is low, 'max' (the limit is hit) is medium, 'oom' and 'oom_kill' are critical.
+// A level is reported until there are no such events for the observer's event timeout.
+type cgroupV2Memory struct {
+	observer   *CgroupsObserver
+	cgroup     string
+	dir        string
+	mtx        sync.Mutex
+	lastEvents map[string]int64
+	lastLevels [3]time.Time
+}
+
+func newCgroupV2Memory(o *CgroupsObserver, mount string, cgroup string) *cgroupV2Memory {
+	return &cgroupV2Memory{observer: o, cgroup: cgroup, dir: path.Join(mount, cgroup)}
+}
+
+var cgroupV2LevelEvents = [3][]string{
+	{"high"},
+	{"max"},
+	{"oom", "oom_kill"},
+}
+
+func (w *cgroupV2Memory) start() {
+	eventsFile := w.observer.reader.resolvePath(path.Join(w.dir, "memory.events"))
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		log.Print("inotify_init1 failed: ", err)
+		return
+	}
+	if _, err = syscall.InotifyAddWatch(fd, eventsFile, syscall.IN_MODIFY); err != nil {
+		log.Printf("Failed to watch '%s': %v", eventsFile, err)
+		syscall.Close(fd)
+		return
+	}
+
+	w.update(time.Now())
+	go w.startWatching(fd)
+}
+
+func (w *cgroupV2Memory) startWatching(fd int) {
+	defer syscall.Close(fd)
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		log.Print("epoll_create1 failed: ", err)
+		return
+	}
+	defer syscall.Close(epfd)
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		log.Print("Failed to setup epoll(): ", err)
+		return
+	}
+
+	var events [1]syscall.EpollEvent
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.EpollWait(epfd, events[:], int(cgroupV2StatsInterval/time.Millisecond))
+		if err != nil && err != syscall.EINTR {
+			log.Print("epoll_wait failed: ", err)
+			return
+		}
+		if n > 0 {
+			// the content of inotify events doesn't matter, there is only one watched file
+			syscall.Read(fd, buf)
+		}
+		w.update(time.Now())
+	}
+}
+
+// update reads the events and the memory stats of the cgroup and reports its pressure
+func (w *cgroupV2Memory) update(now time.Time) {
+	w.mtx.Lock()
+	events, err := readCgroupEvents(w.observer.reader, path.Join(w.dir, "memory.events"))
+	if err != nil {
+		log.Print(err)
+	} else {
+		if w.lastEvents != nil {
+			for level, keys := range cgroupV2LevelEvents {
+				for _, k := range keys {
+					if events[k] > w.lastEvents[k] {
+						w.lastLevels[level] = now
+					}
+				}
+			}
+		}
+		w.lastEvents = events
+	}
+	var active [3]bool
+	for level, last := range w.lastLevels {
+		active[level] = !last.IsZero() && now.Sub(last) < w.observer.eventTimeout
+	}
+	w.mtx.Unlock()
+
+	w.observer.report(w.cgroup, pressureMask(active[0], active[1], active[2]))
+	w.trackStats()
+}
+
+func (w *cgroupV2Memory) trackStats() {
+	for key, file := range map[string]string{
+		cgroupMemCurrentKey:  "memory.current",
+		cgroupMemMaxKey:      "memory.max",
+		cgroupMemHighKey:     "memory.high",
+		cgroupSwapCurrentKey: "memory.swap.current",
+	} {
+		value := math.NaN()
+		// 'memory.swap.current' is missing, if swap accounting is disabled
+		if text, err := readCgroupFile(w.observer.reader, path.Join(w.dir, file)); err == nil {
+			value = cgroupV2Megabytes(text)
+		}
+		w.observer.track(key, w.cgroup, value)
+	}
+}
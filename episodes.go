@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// Episode is a contiguous period of time when a detector reported memory pressure
+type Episode struct {
+	Detector  string
+	Start     time.Time
+	End       time.Time
+	StartTime int64
+	EndTime   int64
+	Peak      float64
+	Allocated float64
+	Open      bool
+}
+
+func (e *Episode) duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+type episodeDetector struct {
+	metric    string
+	threshold float64
+}
+
+// EpisodeTracker is a sink, which tracks pressure episodes of every detector
+// and prints the summary table on exit
+type EpisodeTracker struct {
+	enabled         bool
+	streamEvents    bool
+	psiSomeLimit    float64
+	psiFullLimit    float64
+	memPercentLimit float64
+	faultsMultLimit float64
+	detectors       []episodeDetector
+	open            map[string]*Episode
+	episodes        []*Episode
+	out             io.Writer
+}
+
+func (o *EpisodeTracker) SetFlags() {
+	flag.BoolVar(&o.enabled, "episodes", false, "track pressure episodes of every detector and print the summary table on exit")
+	flag.BoolVar(&o.streamEvents, "episodeEvents", false, "log every pressure episode start and end")
+	flag.Float64Var(&o.psiSomeLimit, "episodePsiSome", 10, "'psi_some' threshold (in percents) for the pressure episode, 0 to disable")
+	flag.Float64Var(&o.psiFullLimit, "episodePsiFull", 5, "'psi_full' threshold (in percents) for the pressure episode, 0 to disable")
+	flag.Float64Var(&o.memPercentLimit, "episodeMemPercent", 90, "'mem_pcnt' threshold (in percents) for the pressure episode, 0 to disable")
+	flag.Float64Var(&o.faultsMultLimit, "episodeFaultsMult", 3, "'swp_flts_mult' threshold for the pressure episode, 0 to disable")
+}
+
+func (o *EpisodeTracker) Initialize() {
+	o.out = os.Stderr
+	o.open = make(map[string]*Episode)
+	// kernel-side detectors report pressure with any non-zero bit mask
+	o.detectors = []episodeDetector{
+		{cgroupsPressureKey, 0},
+		{psiTriggersKey, 0},
+	}
+	thresholds := []episodeDetector{
+		{psiSomeKey, o.psiSomeLimit},
+		{psiFullKey, o.psiFullLimit},
+		{memPercentKey, o.memPercentLimit},
+		{faultsMultiplierKey, o.faultsMultLimit},
+	}
+	for _, d := range thresholds {
+		if d.threshold > 0 {
+			o.detectors = append(o.detectors, d)
+		}
+	}
+}
+
+func (o *EpisodeTracker) threshold(metric string) (float64, bool) {
+	for _, d := range o.detectors {
+		if d.metric == metric {
+			return d.threshold, true
+		}
+	}
+	return 0, false
+}
+
+func sampleNumber(s *Sample, column string) float64 {
+	for _, v := range s.Values {
+		if v.Column == column {
+			if f, ok := numericValue(v.Value); ok {
+				return f
+			}
+		}
+	}
+	return math.NaN()
+}
+
+func (o *EpisodeTracker) Write(s *Sample) error {
+	elapsed := int64(sampleNumber(s, timeKey))
+	for _, v := range s.Values {
+		threshold, ok := o.threshold(v.Metric.Name)
+		if !ok {
+			continue
+		}
+		value, ok := numericValue(v.Value)
+		active := ok && value > threshold // NaN is never above the threshold
+
+		episode, isOpen := o.open[v.Column]
+		switch {
+		case active && !isOpen:
+			episode = &Episode{
+				Detector:  v.Column,
+				Start:     s.Timestamp,
+				End:       s.Timestamp,
+				StartTime: elapsed,
+				EndTime:   elapsed,
+				Peak:      value,
+				Allocated: sampleNumber(s, allocatedKey),
+				Open:      true,
+			}
+			o.open[v.Column] = episode
+			o.episodes = append(o.episodes, episode)
+			o.report("started", episode)
+		case active && isOpen:
+			episode.End = s.Timestamp
+			episode.EndTime = elapsed
+			episode.Peak = math.Max(episode.Peak, value)
+		case !active && isOpen:
+			episode.End = s.Timestamp
+			episode.EndTime = elapsed
+			episode.Open = false
+			delete(o.open, v.Column)
+			o.report("ended", episode)
+		}
+	}
+	return nil
+}
+
+func (o *EpisodeTracker) report(what string, e *Episode) {
+	if o.streamEvents {
+		log.Printf("Pressure episode %s: detector=%s start=%d end=%d peak=%.2f alloctd=%.0f",
+			what, e.Detector, e.StartTime, e.EndTime, e.Peak, e.Allocated)
+	}
+}
+
+// Close prints the summary table, episodes, which are still open, are marked with '*'
+func (o *EpisodeTracker) Close() error {
+	fmt.Fprintf(o.out, "Pressure episodes (%d):\n", len(o.episodes))
+	if len(o.episodes) == 0 {
+		return nil
+	}
+	fmt.Fprintf(o.out, "%24s, %8s, %8s, %8s, %10s, %8s\n", "detector", "start", "end", "duration", "peak", "alloctd")
+	for _, e := range o.episodes {
+		end := fmt.Sprint(e.EndTime)
+		if e.Open {
+			end += "*"
+		}
+		fmt.Fprintf(o.out, "%24s, %8d, %8s, %8.0f, %10.2f, %8.0f\n",
+			e.Detector, e.StartTime, end, e.duration().Seconds(), e.Peak, e.Allocated)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import "bytes"
+import "strings"
+import "testing"
+import "time"
+
+func TestEpisodeTracking(t *testing.T) {
+	var buf bytes.Buffer
+	o := EpisodeTracker{psiFullLimit: 5}
+	o.Initialize()
+	o.out = &buf
+
+	tr := Tracker{}
+	start := time.Unix(1568822281, 0)
+	steps := []struct {
+		psiFull  float64
+		psiTrig  int
+		allocted int
+	}{
+		{0, 0, 128},
+		{6, 0, 256},
+		{12, 1, 384},
+		{3, 1, 512},
+		{1, 0, 640},
+		{8, 0, 768},
+	}
+	for i, step := range steps {
+		tr.trackOne(timeKey, int64(i*5))
+		tr.trackOne(psiFullKey, step.psiFull)
+		tr.trackOne(psiTriggersKey, step.psiTrig)
+		tr.trackOne(allocatedKey, step.allocted)
+		s := tr.sample("timer")
+		s.Timestamp = start.Add(time.Duration(i*5) * time.Second)
+		o.Write(s)
+	}
+	o.Close()
+
+	if len(o.episodes) != 3 {
+		t.Fatalf("Expected 3 episodes, got %d:\n%s", len(o.episodes), buf.String())
+	}
+	first := o.episodes[0]
+	if first.Detector != psiFullKey || first.StartTime != 5 || first.EndTime != 15 || first.Peak != 12 || first.Allocated != 256 {
+		t.Errorf("Wrong first episode %+v", *first)
+	}
+	if first.duration() != 10*time.Second {
+		t.Errorf("Wrong first episode duration %v", first.duration())
+	}
+	second := o.episodes[1]
+	if second.Detector != psiTriggersKey || second.StartTime != 10 || second.EndTime != 20 || second.Open {
+		t.Errorf("Wrong second episode %+v", *second)
+	}
+	if !o.episodes[2].Open {
+		t.Error("The last episode must be still open")
+	}
+	if !strings.Contains(buf.String(), "25*") {
+		t.Errorf("Open episode must be marked in the table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	incidentOomKill   = "oom_kill"
+	incidentSlowAlloc = "slow_alloc"
+	incidentPsiSpike  = "psi_full_spike"
+	incidentCgroupOom = "cgroup_oom"
+)
+
+// incident is a ground-truth event, which detectors should warn about in advance
+type incident struct {
+	kind      string
+	time      int64
+	allocated float64
+}
+
+// Evaluator is a sink, which finds ground-truth incidents (OOM kills, cgroup v1 OOM notifications,
+// slow allocations and 'psi_full' spikes) and calculates how early every detector warned about them,
+// using the episodes from EpisodeTracker. The report is printed on exit.
+type Evaluator struct {
+	enabled        bool
+	slowAllocMs    float64
+	psiFullSpike   float64
+	graceSeconds   int64
+	episodes       *EpisodeTracker
+	incidents      []incident
+	activeIncident map[string]bool
+	lastOomEvents  map[string]float64
+	out            io.Writer
+}
+
+func (o *Evaluator) SetFlags() {
+	flag.BoolVar(&o.enabled, "evaluate", false, "calculate detectors lead time before incidents and print the report on exit, requires '-episodes'")
+	flag.Float64Var(&o.slowAllocMs, "evalSlowAllocMs", 500, "block allocation time (in milliseconds), which is considered as an incident, 0 to disable")
+	flag.Float64Var(&o.psiFullSpike, "evalPsiFullSpike", 20, "'psi_full' value (in percents), which is considered as an incident, 0 to disable")
+	flag.Int64Var(&o.graceSeconds, "evalGrace", 10, "time (in seconds) after the pressure episode end, during which an incident is still related to this episode")
+}
+
+func (o *Evaluator) Initialize(episodes *EpisodeTracker) {
+	o.episodes = episodes
+	o.out = os.Stderr
+	o.activeIncident = make(map[string]bool)
+	o.lastOomEvents = make(map[string]float64)
+}
+
+// Write detects incidents, every one is counted once at its onset
+func (o *Evaluator) Write(s *Sample) error {
+	happening := make(map[string]bool)
+	for _, v := range s.Values {
+		value, ok := numericValue(v.Value)
+		if !ok {
+			continue
+		}
+		switch v.Metric.Name {
+		case oomNewKey, cgroupOomNewKey:
+			happening[incidentOomKill] = happening[incidentOomKill] || value > 0
+		case cgroupUnderOomKey:
+			happening[incidentCgroupOom] = happening[incidentCgroupOom] || value > 0
+		case cgroupOomEventsKey:
+			// the counter starts from zero
+			happening[incidentCgroupOom] = happening[incidentCgroupOom] || value > o.lastOomEvents[v.Column]
+			o.lastOomEvents[v.Column] = value
+		case allocationTimeKey:
+			happening[incidentSlowAlloc] = o.slowAllocMs > 0 && value > o.slowAllocMs
+		case psiFullKey:
+			happening[incidentPsiSpike] = happening[incidentPsiSpike] || (o.psiFullSpike > 0 && value > o.psiFullSpike)
+		}
+	}
+
+	elapsed := int64(sampleNumber(s, timeKey))
+	for _, kind := range []string{incidentOomKill, incidentCgroupOom, incidentSlowAlloc, incidentPsiSpike} {
+		// every update with new OOM kills is a separate incident
+		if happening[kind] && (!o.activeIncident[kind] || kind == incidentOomKill) {
+			o.incidents = append(o.incidents, incident{kind, elapsed, sampleNumber(s, allocatedKey)})
+		}
+		o.activeIncident[kind] = happening[kind]
+	}
+	return nil
+}
+
+// warningEpisode returns the episode of the detector, which was active at the incident time
+// or ended not earlier than grace period before it
+func (o *Evaluator) warningEpisode(detector string, i incident) *Episode {
+	var result *Episode
+	for _, e := range o.episodes.episodes {
+		if e.Detector != detector || e.StartTime > i.time {
+			continue
+		}
+		if e.Open || e.EndTime+o.graceSeconds >= i.time {
+			result = e
+		}
+	}
+	return result
+}
+
+// isFalsePositive checks that the episode resolved without any incident
+func (o *Evaluator) isFalsePositive(e *Episode) bool {
+	if e.Open {
+		return false
+	}
+	for _, i := range o.incidents {
+		if i.time >= e.StartTime && i.time <= e.EndTime+o.graceSeconds {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *Evaluator) detectors() []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, e := range o.episodes.episodes {
+		if !seen[e.Detector] {
+			seen[e.Detector] = true
+			result = append(result, e.Detector)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (o *Evaluator) Close() error {
+	fmt.Fprintf(o.out, "Incidents (%d):\n", len(o.incidents))
+	if len(o.incidents) > 0 {
+		fmt.Fprintf(o.out, "%16s, %8s, %8s\n", "incident", "time", "alloctd")
+		for _, i := range o.incidents {
+			fmt.Fprintf(o.out, "%16s, %8d, %8.0f\n", i.kind, i.time, i.allocated)
+		}
+	}
+
+	detectors := o.detectors()
+	if len(detectors) == 0 {
+		return nil
+	}
+	fmt.Fprintln(o.out, "Detectors lead time (seconds / megabytes before the first incident of every kind, '-' if missed):")
+	fmt.Fprintf(o.out, "%24s, ", "detector")
+	var kinds []string
+	first := make(map[string]incident)
+	for _, i := range o.incidents {
+		if _, ok := first[i.kind]; !ok {
+			first[i.kind] = i
+			kinds = append(kinds, i.kind)
+		}
+	}
+	for _, kind := range kinds {
+		fmt.Fprintf(o.out, "%16s, ", kind)
+	}
+	fmt.Fprintf(o.out, "%8s, %15s\n", "episodes", "false positives")
+
+	for _, d := range detectors {
+		fmt.Fprintf(o.out, "%24s, ", d)
+		for _, kind := range kinds {
+			i := first[kind]
+			lead := "-"
+			if e := o.warningEpisode(d, i); e != nil {
+				lead = fmt.Sprintf("%d s", i.time-e.StartTime)
+				if allocated := i.allocated - e.Allocated; !math.IsNaN(allocated) {
+					lead += fmt.Sprintf(" / %.0f Mb", allocated)
+				}
+			}
+			fmt.Fprintf(o.out, "%16s, ", lead)
+		}
+		total := 0
+		falsePositives := 0
+		for _, e := range o.episodes.episodes {
+			if e.Detector == d {
+				total++
+				if o.isFalsePositive(e) {
+					falsePositives++
+				}
+			}
+		}
+		fmt.Fprintf(o.out, "%8d, %15d\n", total, falsePositives)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import "bytes"
+import "strings"
+import "testing"
+
+func TestEvaluateLeadTime(t *testing.T) {
+	var buf bytes.Buffer
+	episodes := EpisodeTracker{psiFullLimit: 5}
+	episodes.Initialize()
+	episodes.out = &buf
+	o := Evaluator{slowAllocMs: 500, psiFullSpike: 20, graceSeconds: 5}
+	o.Initialize(&episodes)
+	o.out = &buf
+
+	tr := Tracker{}
+	steps := []struct {
+		cgroups int
+		psiFull float64
+		allocMs float64
+		oomNew  int64
+	}{
+		{1, 0, 10, 0},   // 0: false positive episode of 'cgroups'
+		{0, 0, 10, 0},   // 5
+		{0, 0, 10, 0},   // 10
+		{1, 0, 10, 0},   // 15: 'cgroups' warns
+		{1, 6, 700, 0},  // 20: 'psi_full' warns, slow allocation
+		{3, 25, 900, 0}, // 25: 'psi_full' spike
+		{7, 40, 900, 1}, // 30: OOM kill
+	}
+	for i, step := range steps {
+		tr.trackOne(timeKey, int64(i*5))
+		tr.trackOne(allocatedKey, (i+1)*128)
+		tr.trackOne(cgroupsPressureKey, step.cgroups)
+		tr.trackOne(psiFullKey, step.psiFull)
+		tr.trackOne(allocationTimeKey, step.allocMs)
+		tr.trackOne(oomNewKey, step.oomNew)
+		s := tr.sample("timer")
+		episodes.Write(s)
+		o.Write(s)
+	}
+	o.Close()
+
+	if len(o.incidents) != 3 {
+		t.Fatalf("Expected 3 incidents, got %d", len(o.incidents))
+	}
+	oom := o.incidents[2]
+	if oom.kind != incidentOomKill || oom.time != 30 || oom.allocated != 896 {
+		t.Errorf("Wrong OOM incident %+v", oom)
+	}
+
+	e := o.warningEpisode(cgroupsPressureKey, oom)
+	if e == nil || oom.time-e.StartTime != 15 || oom.allocated-e.Allocated != 384 {
+		t.Errorf("Wrong 'cgroups' warning episode %+v", e)
+	}
+	if !o.isFalsePositive(episodes.episodes[0]) {
+		t.Error("The first 'cgroups' episode must be a false positive")
+	}
+	if !strings.Contains(buf.String(), "15 s / 384 Mb") {
+		t.Errorf("Lead time is missing in the report:\n%s", buf.String())
+	}
+}
+
+func TestEvaluateCgroupOom(t *testing.T) {
+	episodes := EpisodeTracker{}
+	episodes.Initialize()
+	o := Evaluator{}
+	o.Initialize(&episodes)
+
+	tr := Tracker{}
+	tr.register(
+		Metric{Name: cgroupOomEventsKey, Type: Counter, Labels: []string{"cgroup"}},
+		Metric{Name: cgroupUnderOomKey, Type: Gauge, Labels: []string{"cgroup"}},
+	)
+	labels := Labels{{"cgroup", "/workload"}}
+	steps := []struct {
+		events   int
+		underOom int
+	}{
+		{0, 0},
+		{1, 0}, // the OOM killer has resolved it before the update
+		{1, 0},
+		{2, 1}, // the OOM killer is disabled
+		{2, 1},
+		{2, 0},
+	}
+	for i, step := range steps {
+		tr.trackOne(timeKey, int64(i))
+		tr.trackLabeled(cgroupOomEventsKey, labels, step.events)
+		tr.trackLabeled(cgroupUnderOomKey, labels, step.underOom)
+		o.Write(tr.sample("timer"))
+	}
+
+	if len(o.incidents) != 2 {
+		t.Fatalf("Expected 2 incidents, got %+v", o.incidents)
+	}
+	for i, expected := range []int64{1, 3} {
+		if o.incidents[i].kind != incidentCgroupOom || o.incidents[i].time != expected {
+			t.Errorf("Expected cgroup OOM incident at %d, got %+v", expected, o.incidents[i])
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+)
+
+const (
+	hookMedium   = "medium"
+	hookCritical = "critical"
+	hookRecover  = "recover"
+)
+
+const hookEnvPrefix = "MEMORY_PRESSURE_"
+
+// hookWaitDelay limits the time to wait for the hook output after the command is killed
+const hookWaitDelay = time.Second
+
+// Hook is an external command, which is run on the detector's pressure level transition
+type Hook struct {
+	Detector string
+	Event    string
+	Command  string
+}
+
+// hookEvent maps a pressure level transition to a hook event, it returns false if there is no event:
+// 'medium' and 'critical' are reported when the level rises to them, 'recover' - when it drops below medium
+func hookEvent(previous, actual PressureLevel) (string, bool) {
+	switch {
+	case actual > previous && actual == LevelMedium:
+		return hookMedium, true
+	case actual > previous && actual == LevelCritical:
+		return hookCritical, true
+	case previous >= LevelMedium && actual < LevelMedium:
+		return hookRecover, true
+	}
+	return "", false
+}
+
+// hookList is a repeatable '-hook' command-line option
+type hookList []Hook
+
+func (l *hookList) String() string {
+	var hooks []string
+	for _, h := range *l {
+		hooks = append(hooks, h.Detector+":"+h.Event)
+	}
+	return strings.Join(hooks, ",")
+}
+
+func (l *hookList) Set(value string) error {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return fmt.Errorf("Hook is expected as 'detector:event:command', got '%s'", value)
+	}
+	switch parts[1] {
+	case hookMedium, hookCritical, hookRecover:
+	default:
+		return fmt.Errorf("Unknown hook event '%s', expected '%s', '%s' or '%s'", parts[1], hookMedium, hookCritical, hookRecover)
+	}
+	*l = append(*l, Hook{parts[0], parts[1], parts[2]})
+	return nil
+}
+
+// hookEnvName converts a series name to the environment variable name,
+// e.g. 'pressure_level{detector=psi_some}' to 'MEMORY_PRESSURE_PRESSURE_LEVEL_DETECTOR_PSI_SOME'
+func hookEnvName(column string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, column)
+	return hookEnvPrefix + strings.Trim(name, "_")
+}
+
+// HookRunner runs external commands on transitions of the combined pressure level
+// or pressure levels of the specific detectors, so services can react to the pressure
+type HookRunner struct {
+	tracker     *Tracker
+	onMedium    string
+	onCritical  string
+	onRecover   string
+	hooks       hookList
+	timeout     time.Duration
+	concurrency int
+	running     chan bool
+	levels      map[string]PressureLevel
+	wg          sync.WaitGroup
+	// hooks are only logged in a replay, the recorded pressure isn't the actual one
+	logOnly bool
+}
+
+func (o *HookRunner) SetFlags() {
+	flag.StringVar(&o.onMedium, "onMedium", "", "command to run when the combined pressure level rises to medium, empty to disable")
+	flag.StringVar(&o.onCritical, "onCritical", "", "command to run when the combined pressure level rises to critical, empty to disable")
+	flag.StringVar(&o.onRecover, "onRecover", "", "command to run when the combined pressure level drops below medium, empty to disable")
+	flag.Var(&o.hooks, "hook", "command to run on the detector's pressure level transition as 'detector:event:command', where event is 'medium', 'critical' or 'recover', can be repeated")
+	flag.DurationVar(&o.timeout, "hookTimeout", 10*time.Second, "time limit for a hook command, the command is killed after that")
+	flag.IntVar(&o.concurrency, "hookConcurrency", 2, "maximum number of hook commands running at once, hooks above the limit are skipped")
+}
+
+// configured checks whether any hook is set on the command line
+func (o *HookRunner) configured() bool {
+	return o.onMedium != "" || o.onCritical != "" || o.onRecover != "" || len(o.hooks) > 0
+}
+
+func (o *HookRunner) Initialize(t *Tracker) {
+	o.tracker = t
+	o.levels = make(map[string]PressureLevel)
+	for _, h := range []Hook{
+		{combinedLevelKey, hookMedium, o.onMedium},
+		{combinedLevelKey, hookCritical, o.onCritical},
+		{combinedLevelKey, hookRecover, o.onRecover},
+	} {
+		if h.Command != "" {
+			o.hooks = append(o.hooks, h)
+		}
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	o.running = make(chan bool, o.concurrency)
+}
+
+func (o *HookRunner) Process() {
+	if len(o.hooks) == 0 {
+		return
+	}
+	for _, v := range o.tracker.snapshot() {
+		var detector string
+		switch {
+		case v.Metric.Name == combinedLevelKey:
+			detector = combinedLevelKey
+		case v.Metric.Name == detectorLevelKey && len(v.Labels) == 1:
+			detector = v.Labels[0].Value
+		default:
+			continue
+		}
+		value, ok := numericValue(v.Value)
+		if !ok {
+			continue
+		}
+		// a detector, which has stopped working, has no pressure any more, so it recovers
+		level := LevelNone
+		if !math.IsNaN(value) {
+			level = PressureLevel(value)
+		}
+		previous := o.levels[detector]
+		o.levels[detector] = level
+		if event, ok := hookEvent(previous, level); ok {
+			o.fire(detector, event, previous, level)
+		}
+	}
+}
+
+func (o *HookRunner) fire(detector, event string, previous, level PressureLevel) {
+	for _, h := range o.hooks {
+		if h.Detector != detector || h.Event != event {
+			continue
+		}
+		if o.logOnly {
+			log.Printf("Hook '%s' for '%s' of '%s' would run (replay)", h.Command, event, detector)
+			continue
+		}
+		select {
+		case o.running <- true:
+		default:
+			log.Printf("Hook '%s' for '%s' of '%s' is skipped, %d hooks are already running", h.Command, event, detector, o.concurrency)
+			continue
+		}
+		env, input := o.hookInput(h, previous, level)
+		o.wg.Add(1)
+		go func(h Hook) {
+			defer o.wg.Done()
+			defer func() { <-o.running }()
+			o.run(h, env, input)
+		}(h)
+	}
+}
+
+// hookInput returns the environment and the JSON input for the hook command, both have the actual values of all the metrics
+func (o *HookRunner) hookInput(h Hook, previous, level PressureLevel) ([]string, []byte) {
+	s := o.tracker.sample(h.Event)
+	env := append(os.Environ(),
+		hookEnvPrefix+"EVENT="+h.Event,
+		hookEnvPrefix+"DETECTOR="+h.Detector,
+		hookEnvPrefix+"LEVEL="+level.String(),
+		hookEnvPrefix+"PREVIOUS_LEVEL="+previous.String(),
+	)
+	for _, v := range s.Values {
+		env = append(env, hookEnvName(v.Column)+"="+fmt.Sprint(v.Value))
+	}
+
+	var input bytes.Buffer
+	sink := JSONLSink{out: nopCloser{&input}}
+	sink.Write(s)
+	return env, input.Bytes()
+}
+
+// run executes the hook command in its own process group, so the whole group
+// including the children of the command is killed on timeout
+func (o *HookRunner) run(h Hook, env []string, input []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	log.Printf("Running hook '%s' for '%s' of '%s'", h.Command, h.Event, h.Detector)
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookWaitDelay
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("Hook '%s' is killed after %v timeout", h.Command, o.timeout)
+	} else if err != nil {
+		log.Printf("Hook '%s' has failed: %v, output: %s", h.Command, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// wait blocks until all the running hooks complete, but not longer than the hook timeout
+func (o *HookRunner) wait() {
+	done := make(chan bool)
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(o.timeout + hookWaitDelay):
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import "math"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+func TestHookEvent(t *testing.T) {
+	cases := []struct {
+		previous PressureLevel
+		actual   PressureLevel
+		expected string
+	}{
+		{LevelNone, LevelLow, ""},
+		{LevelLow, LevelMedium, hookMedium},
+		{LevelNone, LevelCritical, hookCritical},
+		{LevelCritical, LevelMedium, ""},
+		{LevelMedium, LevelLow, hookRecover},
+		{LevelCritical, LevelNone, hookRecover},
+		{LevelLow, LevelNone, ""},
+	}
+	for _, c := range cases {
+		event, _ := hookEvent(c.previous, c.actual)
+		if event != c.expected {
+			t.Errorf("Expected '%s' event for '%s' -> '%s', got '%s'", c.expected, c.previous, c.actual, event)
+		}
+	}
+}
+
+func TestHookEnvName(t *testing.T) {
+	if name := hookEnvName("pressure_level{detector=psi_some}"); name != "MEMORY_PRESSURE_PRESSURE_LEVEL_DETECTOR_PSI_SOME" {
+		t.Errorf("Unexpected environment variable name '%s'", name)
+	}
+}
+
+func TestHookRunner(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	tr := Tracker{}
+	o := HookRunner{timeout: 5 * time.Second, concurrency: 1}
+	o.onCritical = "echo $MEMORY_PRESSURE_EVENT $MEMORY_PRESSURE_PSI_FULL > " + out + "; cat >> " + out
+	o.hooks.Set("psi_trig:recover:echo recovered >> " + out)
+	o.Initialize(&tr)
+
+	tr.trackOne(psiFullKey, 17.5)
+	tr.trackOne(combinedLevelKey, int(LevelCritical))
+	o.Process()
+	o.wait()
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Hook hasn't run: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || lines[0] != "critical 17.5" || !strings.Contains(lines[1], `"psi_full":17.5`) {
+		t.Errorf("Unexpected hook output:\n%s", content)
+	}
+
+	// the same level doesn't run the hook again
+	o.Process()
+	o.wait()
+	if again, _ := os.ReadFile(out); string(again) != string(content) {
+		t.Errorf("Hook has run twice:\n%s", again)
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	tr := Tracker{}
+	o := HookRunner{timeout: 300 * time.Millisecond, concurrency: 1}
+	// the child of the shell keeps the output pipe open
+	o.onMedium = "echo started; sleep 5; echo finished"
+	o.Initialize(&tr)
+
+	start := time.Now()
+	tr.trackOne(combinedLevelKey, int(LevelMedium))
+	o.Process()
+	o.wg.Wait()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Hook must be killed after the timeout, it has run for %v", elapsed)
+	}
+	if len(o.running) != 0 {
+		t.Errorf("Killed hook must release its concurrency slot")
+	}
+}
+
+func TestHookLogOnly(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	tr := Tracker{}
+	o := HookRunner{timeout: time.Second, concurrency: 1, logOnly: true}
+	o.onCritical = "touch " + out
+	o.Initialize(&tr)
+
+	tr.trackOne(combinedLevelKey, int(LevelCritical))
+	o.Process()
+	o.wait()
+	if _, err := os.Stat(out); err == nil {
+		t.Errorf("Hook must not run in log-only mode")
+	}
+}
+
+func TestHookStoppedDetector(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	tr := Tracker{}
+	o := HookRunner{timeout: 5 * time.Second, concurrency: 1}
+	o.hooks.Set("psi_some:recover:echo recovered >> " + out)
+	o.Initialize(&tr)
+
+	tr.trackLabeled(detectorLevelKey, Labels{{"detector", psiSomeKey}}, int(LevelCritical))
+	o.Process()
+	tr.trackLabeled(detectorLevelKey, Labels{{"detector", psiSomeKey}}, math.NaN())
+	o.Process()
+	o.wait()
+
+	if content, err := os.ReadFile(out); err != nil || string(content) != "recovered\n" {
+		t.Errorf("Recover hook must run once for the stopped detector, got %q (%v)", content, err)
+	}
+}
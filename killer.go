@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const userOomKillKey = "user_oom_kill"
+
+const (
+	killerOff    = "off"
+	killerDryRun = "dry-run"
+	killerOn     = "on"
+)
+
+const (
+	victimByOomScore = "oom_score"
+	victimByRss      = "rss"
+	victimBySwap     = "swap"
+	victimByTotal    = "rss+swap"
+)
+
+// oomScoreAdjMin means that the kernel OOM killer never kills the process, the userspace one respects it too
+const oomScoreAdjMin = -1000
+
+type victim struct {
+	pid         int
+	name        string
+	startTime   string
+	rss         int64
+	swap        int64
+	oomScore    int64
+	oomScoreAdj int64
+}
+
+func (v *victim) String() string {
+	return fmt.Sprintf("pid %d (%s), rss %d Mb, swap %d Mb, oom_score %d", v.pid, v.name, v.rss/1024, v.swap/1024, v.oomScore)
+}
+
+// OomKiller is a userspace OOM killer, which kills the process with the highest badness,
+// when all the configured rules fire, e.g. 'psi_full > 20 for 10s'.
+// Processes are scanned with its own reader, which has no snapshot, so '/proc/<pid>' files
+// aren't recorded. There are no recorded processes to choose from in a replay, so the killer
+// only logs that the rules have fired.
+type OomKiller struct {
+	tracker  *Tracker
+	reader   Reader
+	mode     string
+	rules    ruleList
+	victimBy string
+	avoid    string
+	avoidRe  *regexp.Regexp
+	grace    time.Duration
+	kills    int64
+	mtx      sync.Mutex
+	killing  bool
+	replay   bool
+}
+
+func (o *OomKiller) SetFlags() {
+	flag.StringVar(&o.mode, "oomKiller", killerOff, "userspace OOM killer mode: 'off', 'dry-run' (only log the victim) or 'on'")
+	flag.Var(&o.rules, "oomKillerWhen", "rule, which must fire to kill a process, as '[name:]series op value [for duration] [clear op value]', e.g. 'psi_full > 20 for 10s', can be repeated, all the rules must fire")
+	flag.StringVar(&o.victimBy, "oomKillerVictimBy", victimByOomScore, "how to choose the victim: the process with the highest 'oom_score', 'rss', 'swap' or 'rss+swap'")
+	flag.StringVar(&o.avoid, "oomKillerAvoid", "", "regular expression for process names, which must never be killed, empty to disable")
+	flag.DurationVar(&o.grace, "oomKillerGrace", 5*time.Second, "time between SIGTERM and SIGKILL to the victim")
+}
+
+func (o *OomKiller) Initialize(t *Tracker) {
+	o.tracker = t
+	switch o.mode {
+	case killerOff:
+		return
+	case killerDryRun, killerOn:
+	default:
+		log.Fatalf("Unknown OOM killer mode '%s'", o.mode)
+	}
+	if len(o.rules) == 0 {
+		log.Fatal("OOM killer requires at least one '-oomKillerWhen' rule")
+	}
+	switch o.victimBy {
+	case victimByOomScore, victimByRss, victimBySwap, victimByTotal:
+	default:
+		log.Fatalf("Unknown OOM killer victim selection '%s'", o.victimBy)
+	}
+	if o.avoid != "" {
+		var err error
+		if o.avoidRe, err = regexp.Compile(o.avoid); err != nil {
+			log.Fatal("Invalid '-oomKillerAvoid' expression: ", err)
+		}
+	}
+
+	o.tracker.register(Metric{Name: userOomKillKey, Type: Counter, Help: "Number of processes killed (or chosen in dry-run mode) by the userspace OOM killer"})
+	o.tracker.trackOne(userOomKillKey, o.kills)
+	log.Printf("Userspace OOM killer is in '%s' mode", o.mode)
+}
+
+func (o *OomKiller) Process() {
+	if o.mode == killerOff {
+		return
+	}
+	values := seriesValues(o.tracker)
+	now := o.tracker.now()
+	firing := true
+	for _, r := range o.rules {
+		r.update(r.value(values), now)
+		firing = firing && r.state == AlertFiring
+	}
+	if !firing || o.isKilling() {
+		return
+	}
+	if o.replay {
+		log.Print("OOM killer rules have fired, no victim is chosen in a replay")
+		o.reset()
+		return
+	}
+
+	v := o.findVictim()
+	if v == nil {
+		log.Print("OOM killer hasn't found a process to kill")
+		return
+	}
+	if !o.kill(v) {
+		return
+	}
+	o.kills++
+	o.tracker.trackOne(userOomKillKey, o.kills)
+	o.reset()
+}
+
+// reset makes the rules fire again before the next kill, so the system has time to recover
+func (o *OomKiller) reset() {
+	for _, r := range o.rules {
+		r.state = AlertInactive
+	}
+}
+
+func (o *OomKiller) isKilling() bool {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return o.killing
+}
+
+// readVictim reads the process info from '/proc/<pid>', it returns nil for kernel threads
+func (o *OomKiller) readVictim(pid int) *victim {
+	dir := path.Join(defaultProcRoot, strconv.Itoa(pid))
+	statusFile := path.Join(dir, "status")
+	rss, err := o.reader.getIntValue(statusFile, "VmRSS")
+	if err != nil {
+		// kernel threads have no memory, and the process may have exited already
+		return nil
+	}
+	v := &victim{pid: pid, rss: rss}
+	v.name, v.startTime = o.identity(pid)
+	v.swap, _ = o.reader.getIntValue(statusFile, "VmSwap")
+	v.oomScore, _ = o.reader.getIntWhole(path.Join(dir, "oom_score"))
+	v.oomScoreAdj, _ = o.reader.getIntWhole(path.Join(dir, "oom_score_adj"))
+	return v
+}
+
+// identity returns the process name and start time, which tell a reused pid from the original process
+func (o *OomKiller) identity(pid int) (string, string) {
+	dir := path.Join(defaultProcRoot, strconv.Itoa(pid))
+	name, _ := o.reader.getTextWhole(path.Join(dir, "comm"))
+	stat, err := o.reader.getTextWhole(path.Join(dir, "stat"))
+	if err != nil {
+		return name, ""
+	}
+	// the name in the parentheses may contain spaces, start time is the 22nd field
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	const startTimeField = 22 - 3
+	if len(fields) <= startTimeField {
+		return name, ""
+	}
+	return name, fields[startTimeField]
+}
+
+// isAlive checks that the victim is still running, and its pid isn't reused by another process
+func (o *OomKiller) isAlive(v *victim) bool {
+	if syscall.Kill(v.pid, 0) != nil {
+		return false
+	}
+	name, startTime := o.identity(v.pid)
+	return name == v.name && startTime == v.startTime
+}
+
+func (o *OomKiller) badness(v *victim) int64 {
+	switch o.victimBy {
+	case victimByRss:
+		return v.rss
+	case victimBySwap:
+		return v.swap
+	case victimByTotal:
+		return v.rss + v.swap
+	default:
+		return v.oomScore
+	}
+}
+
+// findVictim returns the process with the highest badness, except init, this process,
+// the processes which the kernel OOM killer must not kill and the avoided ones
+func (o *OomKiller) findVictim() *victim {
+	entries, err := ioutil.ReadDir(o.reader.resolvePath(defaultProcRoot))
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	var result *victim
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == 1 || pid == os.Getpid() {
+			continue
+		}
+		v := o.readVictim(pid)
+		if v == nil || v.oomScoreAdj == oomScoreAdjMin {
+			continue
+		}
+		if o.avoidRe != nil && o.avoidRe.MatchString(v.name) {
+			continue
+		}
+		if result == nil || o.badness(v) > o.badness(result) {
+			result = v
+		}
+	}
+	return result
+}
+
+// kill sends SIGTERM to the victim and SIGKILL after the grace period, if it's still alive
+func (o *OomKiller) kill(v *victim) bool {
+	if o.mode == killerDryRun {
+		log.Printf("OOM killer would kill %s (dry run)", v)
+		return true
+	}
+
+	log.Printf("OOM killer is killing %s", v)
+	if err := syscall.Kill(v.pid, syscall.SIGTERM); err != nil {
+		log.Printf("Failed to send SIGTERM to pid %d: %v", v.pid, err)
+		return false
+	}
+	o.mtx.Lock()
+	o.killing = true
+	o.mtx.Unlock()
+	go func() {
+		defer func() {
+			o.mtx.Lock()
+			o.killing = false
+			o.mtx.Unlock()
+		}()
+		time.Sleep(o.grace)
+		if !o.isAlive(v) {
+			return
+		}
+		log.Printf("Pid %d is still alive after %v, sending SIGKILL", v.pid, o.grace)
+		if err := syscall.Kill(v.pid, syscall.SIGKILL); err != nil {
+			log.Printf("Failed to send SIGKILL to pid %d: %v", v.pid, err)
+		}
+	}()
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import "os"
+import "regexp"
+import "testing"
+
+func TestFindVictim(t *testing.T) {
+	cases := []struct {
+		victimBy string
+		avoid    string
+		expected int
+	}{
+		{victimByOomScore, "", 2042},
+		{victimByRss, "", 1021},
+		{victimBySwap, "", 2042},
+		{victimByTotal, "", 1021},
+		{victimByOomScore, "^(java|python)$", 1021},
+	}
+	for _, c := range cases {
+		o := OomKiller{reader: FileReader{procRoot: testProcRoot}, victimBy: c.victimBy}
+		if c.avoid != "" {
+			o.avoidRe = regexp.MustCompile(c.avoid)
+		}
+		v := o.findVictim()
+		if v == nil {
+			t.Errorf("Expected pid %d for '%s', no victim found", c.expected, c.victimBy)
+		} else if v.pid != c.expected {
+			t.Errorf("Expected pid %d for '%s', got %s", c.expected, c.victimBy, v)
+		}
+	}
+}
+
+func TestReadVictim(t *testing.T) {
+	o := OomKiller{reader: FileReader{procRoot: testProcRoot}}
+	v := o.readVictim(2042)
+	if v == nil || v.name != "java" || v.startTime != "204200" || v.rss != 1536000 || v.swap != 512000 || v.oomScore != 700 {
+		t.Errorf("Unexpected victim %v", v)
+	}
+	if v := o.readVictim(4084); v != nil {
+		t.Errorf("Kernel thread can't be a victim, got %s", v)
+	}
+}
+
+func TestOomKillerDryRun(t *testing.T) {
+	tr := Tracker{}
+	o := OomKiller{reader: FileReader{procRoot: testProcRoot}, mode: killerDryRun, victimBy: victimByOomScore}
+	o.rules.Set("mem_pcnt > 90")
+	o.Initialize(&tr)
+
+	tr.trackOne(memPercentKey, 85.0)
+	o.Process()
+	if o.kills != 0 {
+		t.Errorf("Expected no kills below the threshold, got %d", o.kills)
+	}
+	tr.trackOne(memPercentKey, 95.0)
+	o.Process()
+	if o.kills != 1 {
+		t.Errorf("Expected one would-be kill, got %d", o.kills)
+	}
+}
+
+func TestOomKillerReplay(t *testing.T) {
+	tr := Tracker{}
+	o := OomKiller{reader: FileReader{procRoot: testProcRoot}, mode: killerOn, victimBy: victimByOomScore, replay: true}
+	o.rules.Set("mem_pcnt > 90")
+	o.Initialize(&tr)
+
+	tr.trackOne(memPercentKey, 95.0)
+	o.Process()
+	if o.kills != 0 || o.isKilling() {
+		t.Errorf("No process must be chosen in a replay, got %d kills", o.kills)
+	}
+}
+
+func TestVictimIdentity(t *testing.T) {
+	o := OomKiller{reader: FileReader{}}
+	self := o.readVictim(os.Getpid())
+	if self == nil || self.startTime == "" {
+		t.Fatalf("Failed to read this process, got %v", self)
+	}
+	if !o.isAlive(self) {
+		t.Errorf("This process must be alive")
+	}
+	reused := *self
+	reused.startTime = "1"
+	if o.isAlive(&reused) {
+		t.Errorf("Process with another start time must be treated as a reused pid")
+	}
+}
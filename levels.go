@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type PressureLevel int
+
+const (
+	LevelNone PressureLevel = iota
+	LevelLow
+	LevelMedium
+	LevelCritical
+)
+
+func (l PressureLevel) String() string {
+	switch l {
+	case LevelLow:
+		return "low"
+	case LevelMedium:
+		return "medium"
+	case LevelCritical:
+		return "critical"
+	default:
+		return "none"
+	}
+}
+
+const (
+	detectorLevelKey = "pressure_level"
+	combinedLevelKey = "pressure"
+)
+
+const (
+	policyAny      = "any"
+	policyMajority = "majority"
+	policyWeighted = "weighted"
+)
+
+// levelThresholds is a 'low,medium,critical' command-line option
+type levelThresholds [3]float64
+
+func (l *levelThresholds) String() string {
+	return fmt.Sprintf("%g,%g,%g", l[0], l[1], l[2])
+}
+
+func (l *levelThresholds) Set(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != len(l) {
+		return fmt.Errorf("Three comma-separated thresholds are expected, got '%s'", value)
+	}
+	for i, part := range parts {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return err
+		}
+		l[i] = threshold
+	}
+	return nil
+}
+
+func (l *levelThresholds) level(value float64) PressureLevel {
+	for i := len(l) - 1; i >= 0; i-- {
+		if value > l[i] {
+			return PressureLevel(i + 1)
+		}
+	}
+	return LevelNone
+}
+
+// levelWeights is a 'detector=weight,...' command-line option
+type levelWeights map[string]float64
+
+func (w levelWeights) String() string {
+	var parts []string
+	for k, v := range w {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, v))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w levelWeights) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return fmt.Errorf("Weight is expected as 'detector=weight', got '%s'", part)
+		}
+		weight, err := strconv.ParseFloat(pair[1], 64)
+		if err != nil {
+			return err
+		}
+		w[strings.TrimSpace(pair[0])] = weight
+	}
+	return nil
+}
+
+// weight looks for the series weight (e.g. 'cgroups{cgroup=/a}'), and then for the metric one
+func (w levelWeights) weight(detector string) float64 {
+	if weight, ok := w[detector]; ok {
+		return weight
+	}
+	if i := strings.IndexByte(detector, '{'); i >= 0 {
+		if weight, ok := w[detector[:i]]; ok {
+			return weight
+		}
+	}
+	return 1
+}
+
+// cgroupsLevel maps 'critical - medium - low' bit mask
+func cgroupsLevel(value float64) PressureLevel {
+	mask := int(value)
+	switch {
+	case mask&(1<<2) != 0:
+		return LevelCritical
+	case mask&(1<<1) != 0:
+		return LevelMedium
+	case mask&(1<<0) != 0:
+		return LevelLow
+	}
+	return LevelNone
+}
+
+// psiTriggersLevel maps 'critical - medium' bit mask
+func psiTriggersLevel(value float64) PressureLevel {
+	mask := int(value)
+	switch {
+	case mask&(1<<1) != 0:
+		return LevelCritical
+	case mask&(1<<0) != 0:
+		return LevelMedium
+	}
+	return LevelNone
+}
+
+// LevelAggregator maps every detector's output to a common pressure level
+// and combines them into one signal according to the policy
+type LevelAggregator struct {
+	tracker           *Tracker
+	enabled           bool
+	policy            string
+	psiSomeLevels     levelThresholds
+	psiFullLevels     levelThresholds
+	memPercentLevels  levelThresholds
+	faultsMultLevels  levelThresholds
+	weights           levelWeights
+	rules             map[string]func(float64) PressureLevel
+	reported          map[string]bool
+	lastCombinedLevel PressureLevel
+}
+
+func (o *LevelAggregator) SetFlags() {
+	o.psiSomeLevels = levelThresholds{5, 15, 30}
+	o.psiFullLevels = levelThresholds{1, 5, 15}
+	o.memPercentLevels = levelThresholds{80, 90, 95}
+	o.faultsMultLevels = levelThresholds{1.5, 3, 5}
+	o.weights = make(levelWeights)
+	flag.BoolVar(&o.enabled, "levels", false, "map every detector's output to a pressure level and combine them into one signal")
+	flag.StringVar(&o.policy, "levelPolicy", policyAny, "policy to combine detectors pressure levels: 'any' (the highest level), 'majority' or 'weighted'")
+	flag.Var(&o.psiSomeLevels, "levelPsiSome", "'psi_some' thresholds (in percents) for 'low,medium,critical' levels")
+	flag.Var(&o.psiFullLevels, "levelPsiFull", "'psi_full' thresholds (in percents) for 'low,medium,critical' levels")
+	flag.Var(&o.memPercentLevels, "levelMemPercent", "'mem_pcnt' thresholds (in percents) for 'low,medium,critical' levels")
+	flag.Var(&o.faultsMultLevels, "levelFaultsMult", "'swp_flts_mult' thresholds for 'low,medium,critical' levels")
+	flag.Var(o.weights, "levelWeights", "detectors weights for 'weighted' policy as 'detector=weight,...', default weight is 1")
+}
+
+func (o *LevelAggregator) Initialize(t *Tracker) {
+	o.tracker = t
+	if !o.enabled {
+		return
+	}
+	if o.policy != policyAny && o.policy != policyMajority && o.policy != policyWeighted {
+		log.Fatalf("Unknown pressure levels policy '%s'", o.policy)
+	}
+	o.rules = map[string]func(float64) PressureLevel{
+		cgroupsPressureKey:  cgroupsLevel,
+		psiTriggersKey:      psiTriggersLevel,
+		psiSomeKey:          o.psiSomeLevels.level,
+		psiFullKey:          o.psiFullLevels.level,
+		memPercentKey:       o.memPercentLevels.level,
+		faultsMultiplierKey: o.faultsMultLevels.level,
+	}
+	o.reported = make(map[string]bool)
+	o.tracker.register(
+		Metric{Name: detectorLevelKey, Type: Level, Help: "Pressure level reported by the detector (0 - none, 1 - low, 2 - medium, 3 - critical)", Labels: []string{"detector"}},
+		Metric{Name: combinedLevelKey, Type: Level, Help: "Combined pressure level of all the detectors (0 - none, 1 - low, 2 - medium, 3 - critical)"},
+	)
+}
+
+func (o *LevelAggregator) Process() {
+	if !o.enabled {
+		return
+	}
+	levels := make(map[string]PressureLevel)
+	for _, v := range o.tracker.snapshot() {
+		rule, ok := o.rules[v.Metric.Name]
+		if !ok {
+			continue
+		}
+		labels := Labels{{"detector", v.Column}}
+		value, ok := numericValue(v.Value)
+		if !ok || math.IsNaN(value) {
+			// the detector isn't available on this system or has stopped working,
+			// its last level must not stay in the output
+			if o.reported[v.Column] {
+				o.tracker.trackLabeled(detectorLevelKey, labels, math.NaN())
+			}
+			continue
+		}
+		level := rule(value)
+		levels[v.Column] = level
+		o.reported[v.Column] = true
+		o.tracker.trackLabeled(detectorLevelKey, labels, int(level))
+	}
+
+	combined := o.combine(levels)
+	if combined != o.lastCombinedLevel {
+		log.Printf("Combined pressure level has changed from '%s' to '%s'", o.lastCombinedLevel, combined)
+		o.lastCombinedLevel = combined
+	}
+	o.tracker.trackOne(combinedLevelKey, int(combined))
+}
+
+func (o *LevelAggregator) combine(levels map[string]PressureLevel) PressureLevel {
+	if len(levels) == 0 {
+		return LevelNone
+	}
+	switch o.policy {
+	case policyMajority:
+		// the highest level, which is reported (or exceeded) by more than a half of detectors
+		for level := LevelCritical; level > LevelNone; level-- {
+			count := 0
+			for _, l := range levels {
+				if l >= level {
+					count++
+				}
+			}
+			if count*2 > len(levels) {
+				return level
+			}
+		}
+		return LevelNone
+	case policyWeighted:
+		var sum, weights float64
+		for detector, l := range levels {
+			w := o.weights.weight(detector)
+			sum += w * float64(l)
+			weights += w
+		}
+		if weights <= 0 {
+			return LevelNone
+		}
+		return PressureLevel(math.Round(sum / weights))
+	default:
+		result := LevelNone
+		for _, l := range levels {
+			if l > result {
+				result = l
+			}
+		}
+		return result
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import "math"
+import "testing"
+
+func TestLevelThresholds(t *testing.T) {
+	thresholds := levelThresholds{5, 15, 30}
+	cases := []struct {
+		value    float64
+		expected PressureLevel
+	}{
+		{0, LevelNone},
+		{5, LevelNone},
+		{5.1, LevelLow},
+		{20, LevelMedium},
+		{31, LevelCritical},
+	}
+	for _, c := range cases {
+		if level := thresholds.level(c.value); level != c.expected {
+			t.Errorf("Expected level '%s' for %v, got '%s'", c.expected, c.value, level)
+		}
+	}
+
+	if err := thresholds.Set("1, 2,3"); err != nil || thresholds != (levelThresholds{1, 2, 3}) {
+		t.Errorf("Expected thresholds 1,2,3, got %v (%v)", thresholds, err)
+	}
+	if err := thresholds.Set("1,2"); err == nil {
+		t.Errorf("Expected an error for two thresholds")
+	}
+}
+
+func TestBitmaskLevels(t *testing.T) {
+	if level := cgroupsLevel(0); level != LevelNone {
+		t.Errorf("Expected no cgroups pressure, got '%s'", level)
+	}
+	if level := cgroupsLevel(3); level != LevelMedium {
+		t.Errorf("Expected medium cgroups pressure, got '%s'", level)
+	}
+	if level := cgroupsLevel(4); level != LevelCritical {
+		t.Errorf("Expected critical cgroups pressure, got '%s'", level)
+	}
+	if level := psiTriggersLevel(1); level != LevelMedium {
+		t.Errorf("Expected medium PSI triggers pressure, got '%s'", level)
+	}
+	if level := psiTriggersLevel(3); level != LevelCritical {
+		t.Errorf("Expected critical PSI triggers pressure, got '%s'", level)
+	}
+}
+
+func TestCombineLevels(t *testing.T) {
+	levels := map[string]PressureLevel{
+		psiSomeKey:    LevelCritical,
+		psiFullKey:    LevelLow,
+		memPercentKey: LevelNone,
+	}
+	cases := []struct {
+		policy   string
+		weights  levelWeights
+		expected PressureLevel
+	}{
+		{policyAny, nil, LevelCritical},
+		{policyMajority, nil, LevelLow},
+		{policyWeighted, levelWeights{}, LevelLow},
+		{policyWeighted, levelWeights{psiSomeKey: 4}, LevelMedium},
+	}
+	for _, c := range cases {
+		o := LevelAggregator{policy: c.policy, weights: c.weights}
+		if level := o.combine(levels); level != c.expected {
+			t.Errorf("Expected '%s' level for '%s' policy with weights %v, got '%s'", c.expected, c.policy, c.weights, level)
+		}
+	}
+}
+
+func TestLevelAggregator(t *testing.T) {
+	tr := Tracker{}
+	o := LevelAggregator{enabled: true, policy: policyAny, psiSomeLevels: levelThresholds{5, 15, 30}, psiFullLevels: levelThresholds{1, 5, 15}}
+	o.Initialize(&tr)
+
+	tr.trackOne(psiSomeKey, 20.0)
+	tr.trackOne(psiFullKey, math.NaN())
+	tr.trackOne(cgroupsPressureKey, 1)
+	o.Process()
+
+	expected := map[string]float64{
+		"pressure_level{detector=psi_some}": 2,
+		"pressure_level{detector=cgroups}":  1,
+		combinedLevelKey:                    2,
+	}
+	values := snapshotValues(&tr)
+	for column, level := range expected {
+		if values[column] != level {
+			t.Errorf("Expected %v for '%s', got %v", level, column, values[column])
+		}
+	}
+	if _, ok := values["pressure_level{detector=psi_full}"]; ok {
+		t.Errorf("Unavailable 'psi_full' shouldn't have a pressure level")
+	}
+
+	tr.trackOne(psiSomeKey, math.NaN()) // the detector has stopped working
+	o.Process()
+	values = snapshotValues(&tr)
+	if value := values["pressure_level{detector=psi_some}"]; !math.IsNaN(value) {
+		t.Errorf("Level of the stopped detector must be NaN, got %v", value)
+	}
+	if value := values[combinedLevelKey]; value != 1 {
+		t.Errorf("Stopped detector must not affect the combined level, got %v", value)
+	}
+}
+
+func TestLevelAggregatorDisabled(t *testing.T) {
+	tr := Tracker{}
+	o := LevelAggregator{}
+	o.Initialize(&tr)
+	tr.trackOne(psiSomeKey, 20.0)
+	o.Process()
+	if values := snapshotValues(&tr); len(values) != 1 {
+		t.Errorf("Disabled aggregator must not add series, got %v", values)
+	}
+}
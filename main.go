@@ -29,22 +29,40 @@ type ActiveObserver interface {
 	Initialize(t *Tracker, r Reader, c chan bool)
 }
 
+// Processor derives new metrics from the tracked ones on every update
+type Processor interface {
+	SetFlags()
+	Initialize(t *Tracker)
+	Process()
+}
+
 func main() {
 	var blockSizeInMb = flag.Int("blockSize", 128, "block size for every allocation (in Mb), 0 to disable periodical allocator")
 	var initialBlockSizeInMb = flag.Int("initialSize", 0, "size to allocate before test start (in Mb), 0 to disable initial allocation")
 	var allocatePeriodInS = flag.Int("allocInterval", 1, "time delay between allocations (in seconds)")
 	var printPeriodInS = flag.Int("printInterval", 5, "time delay between current status updates (in seconds)")
 	var maximumLimitInMb = flag.Int("limit", 0, "maximum allocated memory size (in Mb), 0 to disable the limit")
+	var outputFormat = flag.String("format", formatTable, "output format: 'table', 'jsonl' (one JSON object per line) or 'csv', ignored if '-sink' is set")
+	var outputFile = flag.String("output", "", "file to write the status updates to, empty for stdout, ignored if '-sink' is set")
+	var httpListen = flag.String("httpListen", "", "address to serve Prometheus metrics on (e.g. 127.0.0.1:9101), empty to disable")
+	var sinkSpecs sinkList
+	flag.Var(&sinkSpecs, "sink", "output sink as 'type[:target]', where type is 'table', 'csv', 'jsonl' (target is a file name, stdout by default) or 'http' (target is a listen address), can be repeated")
+	var recordFile = flag.String("record", "", "file to record raw contents of all the files read by observers to, empty to disable")
+	var replayFile = flag.String("replay", "", "recorded file to replay instead of reading the actual system state, empty to disable")
+	var procRoot = flag.String("procRoot", defaultProcRoot, "procfs mount point, e.g. host's '/proc' bind-mounted to a container")
+	var sysRoot = flag.String("sysRoot", defaultSysRoot, "sysfs mount point, e.g. host's '/sys' bind-mounted to a container")
+	flag.Var(&monitoredCgroups, "cgroup", "cgroup to monitor (e.g. '/workload'), can be repeated, the root memory cgroup for cgroup v1 and the cgroup of this process for cgroup v2 by default")
 	// observer-specific flags are handled by observers in their own SetFlags() funcs
 
 	r := FileReader{}
 	t := Tracker{}
-	t.prepare()
 
 	var passiveObservers []PassiveObserver
 	passiveObservers = append(passiveObservers, &MeminfoObserver{})
 	passiveObservers = append(passiveObservers, &SwapObserver{})
 	passiveObservers = append(passiveObservers, &PsiObserver{})
+	passiveObservers = append(passiveObservers, &OomObserver{})
+	passiveObservers = append(passiveObservers, &CgroupEventsObserver{})
 	for _, element := range passiveObservers {
 		element.SetFlags()
 	}
@@ -57,13 +75,105 @@ func main() {
 		element.SetFlags()
 	}
 
+	var processors []Processor
+	levels := LevelAggregator{}
+	processors = append(processors, &levels)
+	processors = append(processors, &RuleEngine{})
+	// hooks react to the levels, so they go after the aggregator
+	hooks := HookRunner{}
+	processors = append(processors, &hooks)
+	killer := OomKiller{}
+	processors = append(processors, &killer)
+	for _, element := range processors {
+		element.SetFlags()
+	}
+
+	episodes := EpisodeTracker{}
+	episodes.SetFlags()
+	evaluator := Evaluator{}
+	evaluator.SetFlags()
+
 	flag.Parse()
+	r.procRoot = *procRoot
+	r.sysRoot = *sysRoot
+	killer.reader = FileReader{procRoot: *procRoot, sysRoot: *sysRoot}
+
+	if *recordFile != "" && *replayFile != "" {
+		log.Fatal("Recording and replaying can't be done at the same time")
+	}
+	if evaluator.enabled && !episodes.enabled {
+		log.Fatal("Lead time evaluation requires pressure episodes, add '-episodes' option")
+	}
+	if hooks.configured() && !levels.enabled {
+		log.Fatal("Hooks react to pressure levels, add '-levels' option")
+	}
+
+	var replayer *Replayer
+	if *replayFile != "" {
+		var err error
+		if replayer, err = newReplayer(*replayFile); err != nil {
+			log.Fatal(err)
+		}
+		if ok, err := replayer.next(); !ok {
+			log.Fatal("Nothing to replay: ", err)
+		}
+		r.source = replayer
+		t.clock = replayer.now
+	}
+	t.prepare()
+
+	if len(sinkSpecs) == 0 {
+		sinkSpecs.Set(*outputFormat + ":" + *outputFile)
+	}
+	if *httpListen != "" {
+		sinkSpecs.Set(formatHTTP + ":" + *httpListen)
+	}
+	var sinks []Sink
+	for _, spec := range sinkSpecs {
+		sink, err := newSink(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if episodes.enabled {
+		episodes.Initialize()
+		sinks = append(sinks, &episodes)
+		// evaluator uses the episodes, so it must get every sample after the episode tracker
+		if evaluator.enabled {
+			evaluator.Initialize(&episodes)
+			sinks = append(sinks, &evaluator)
+		}
+	}
+	if *recordFile != "" {
+		recorder, err := newRecorder(*recordFile, r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.source = recorder
+		sinks = append(sinks, recorder)
+	}
+	// snapshot is set up after the recorder, which has to read the files on its own
+	snapshot := newSnapshot(t.now)
+	r.snapshot = snapshot
+
+	if replayer != nil {
+		// recorded processes are not the actual ones, so they are never scanned in a replay
+		killer.replay = true
+		hooks.logOnly = true
+		replay(replayer, &t, r, passiveObservers, processors, sinks)
+		return
+	}
+
 	for _, element := range passiveObservers {
 		element.Initialize(&t, r)
 	}
 	for _, element := range activeObservers {
 		element.Initialize(&t, r, notifySink)
 	}
+	for _, element := range processors {
+		element.Initialize(&t)
+	}
 
 	if *blockSizeInMb == 0 {
 		log.Printf("Working in a passive mode, will not allocate memory during the test")
@@ -78,7 +188,8 @@ func main() {
 		}
 	}
 
-	t.prepareAndPrintHeader()
+	process(processors)
+	writeSample(&t, snapshot, "start", sinks)
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
@@ -86,17 +197,70 @@ func main() {
 	ticker := time.NewTicker(time.Duration(*printPeriodInS) * time.Second)
 
 	for {
+		var event string
 		select {
 		case <-sig:
+			closeSinks(sinks)
+			hooks.wait()
 			os.Exit(0)
 		case <-notifySink:
+			event = "notify"
 		case <-ticker.C:
+			event = "timer"
 		}
+		snapshot.reset()
 		for _, element := range passiveObservers {
 			element.TimerEvent()
 		}
-		t.saveTime()
-		t.printData()
+		process(processors)
+		writeSample(&t, snapshot, event, sinks)
+	}
+}
+
+func process(processors []Processor) {
+	for _, element := range processors {
+		element.Process()
+	}
+}
+
+// writeSample completes the iteration of the main loop, the sample is handed to all the sinks
+func writeSample(t *Tracker, snapshot *Snapshot, event string, sinks []Sink) {
+	t.saveTime()
+	s := t.sample(event)
+	s.ReadTime = snapshot.time()
+	writeToSinks(sinks, s)
+}
+
+// replay runs passive observers over the recorded archive, frame by frame,
+// active observers and allocator aren't started, their recorded values are used instead
+func replay(replayer *Replayer, t *Tracker, r FileReader, passiveObservers []PassiveObserver, processors []Processor, sinks []Sink) {
+	defer replayer.Close()
+	defer closeSinks(sinks)
+
+	replayer.restoreValues(t)
+	for _, element := range passiveObservers {
+		element.Initialize(t, r)
+	}
+	for _, element := range processors {
+		element.Initialize(t)
 	}
+	process(processors)
+	writeSample(t, r.snapshot, replayer.event(), sinks)
 
+	for {
+		ok, err := replayer.next()
+		if err != nil {
+			log.Print(err)
+		}
+		if !ok {
+			break
+		}
+		r.snapshot.reset()
+		replayer.restoreValues(t)
+		for _, element := range passiveObservers {
+			element.TimerEvent()
+		}
+		process(processors)
+		writeSample(t, r.snapshot, replayer.event(), sinks)
+	}
 }
@@ -9,6 +9,18 @@ import (
 const zoneFile = "/proc/zoneinfo"
 const meminfoFile = "/proc/meminfo"
 
+const (
+	memTotalKey        = "mem_total"
+	memAvailableKey    = "mem_avail"
+	memAvailableEstKey = "mem_avail_est"
+	memPercentKey      = "mem_pcnt"
+	swapPercentKey     = "swp_pcnt"
+	swapFreeKey        = "swp_free"
+	swapTotalKey       = "swp_total"
+	memReclaimableKey  = "mem_reclaim"
+	memInactiveFileKey = "mem_inactive"
+)
+
 type MeminfoObserver struct {
 	tracker         *Tracker
 	reader          Reader
@@ -27,9 +39,35 @@ func (o *MeminfoObserver) Initialize(t *Tracker, r Reader) {
 	o.reader = r
 	o.pageSize = PageSize()
 	log.Printf("System page size is %d bytes", o.pageSize)
+	metrics := []Metric{
+		{Name: memTotalKey, Type: Gauge, Unit: unitMb, Help: "Total system physical memory", Export: "mem_total_bytes"},
+		{Name: memAvailableKey, Type: Gauge, Unit: unitMb, Help: "Memory available for a new workload without swapping ('MemAvailable')", Export: "mem_available_bytes"},
+		{Name: memAvailableEstKey, Type: Gauge, Unit: unitMb, Help: "Memory available for a new workload, estimated with the legacy kernel algorithm", Export: "mem_available_estimated_bytes"},
+		{Name: memPercentKey, Type: Gauge, Unit: unitPercent, Help: "Percent of physical memory filling", Export: "mem_used_percent"},
+	}
+	if o.hasSwap() {
+		metrics = append(metrics,
+			Metric{Name: swapPercentKey, Type: Gauge, Unit: unitPercent, Help: "Percent of swap usage", Export: "swap_used_percent"},
+			Metric{Name: swapFreeKey, Type: Gauge, Unit: unitMb, Help: "Free swap space", Export: "swap_free_bytes"},
+			Metric{Name: swapTotalKey, Type: Gauge, Unit: unitMb, Help: "Total swap space", Export: "swap_total_bytes"},
+		)
+	}
+	if o.showReclaimable {
+		metrics = append(metrics, Metric{Name: memReclaimableKey, Type: Gauge, Unit: unitMb, Help: "Part of Slab that might be reclaimed ('SReclaimable')", Export: "mem_slab_reclaimable_bytes"})
+	}
+	if o.showInactive {
+		metrics = append(metrics, Metric{Name: memInactiveFileKey, Type: Gauge, Unit: unitMb, Help: "Inactive page cache memory ('Inactive(file)')", Export: "mem_inactive_file_bytes"})
+	}
+	o.tracker.register(metrics...)
 	o.process()
 }
 
+// hasSwap checks whether any swap space is configured, swap metrics are reported only in that case
+func (o *MeminfoObserver) hasSwap() bool {
+	memInfoData, err := o.reader.getFloatKeyValuePairs(meminfoFile)
+	return err == nil && memInfoData["SwapTotal"] > 0
+}
+
 func (o *MeminfoObserver) TimerEvent() {
 	o.process()
 }
@@ -70,16 +108,6 @@ func (o *MeminfoObserver) estimateAvailableMemory(data map[string]float64, lowWa
 }
 
 func (o *MeminfoObserver) analyze() (map[string]interface{}, error) {
-	const totalKey string = "mem_total"
-	const availableKey string = "mem_avail"
-	const availableEstimatedKey string = "mem_avail_est"
-	const percentKey string = "mem_pcnt"
-	const swapPercentKey string = "swp_pcnt"
-	const swapFreeKey string = "swp_free"
-	const swapTotalKey string = "swp_total"
-	const reclaimableKey string = "mem_reclaim"
-	const inactiveFileKey string = "mem_inactive"
-
 	const bytesInKb = 1024
 
 	result := make(map[string]interface{})
@@ -96,33 +124,33 @@ func (o *MeminfoObserver) analyze() (map[string]interface{}, error) {
 
 	memAvailableKb, ok := memInfoData["MemAvailable"]
 	if ok {
-		result[availableEstimatedKey] = memAvailableEstimatedKb / bytesInKb
-		result[availableKey] = memAvailableKb / bytesInKb
+		result[memAvailableEstKey] = memAvailableEstimatedKb / bytesInKb
+		result[memAvailableKey] = memAvailableKb / bytesInKb
 	} else {
 		// No 'MemAvailable' key in 'meminfo', looks like we're running an old kernel
 		memAvailableKb = memAvailableEstimatedKb
-		result[availableKey] = memAvailableEstimatedKb / bytesInKb
+		result[memAvailableKey] = memAvailableEstimatedKb / bytesInKb
 	}
 
 	if o.showReclaimable == true {
 		memReclaimableKb, ok := memInfoData["SReclaimable"]
 		if ok {
-			result[reclaimableKey] = memReclaimableKb / bytesInKb
+			result[memReclaimableKey] = memReclaimableKb / bytesInKb
 		}
 	}
 
 	if o.showInactive == true {
 		memInaciveFileKb, ok := memInfoData["Inactive(file)"]
 		if ok {
-			result[inactiveFileKey] = memInaciveFileKb / bytesInKb
+			result[memInactiveFileKey] = memInaciveFileKb / bytesInKb
 		}
 	}
 
 	memTotalKb, ok := memInfoData["MemTotal"]
-	result[totalKey] = memTotalKb / bytesInKb
+	result[memTotalKey] = memTotalKb / bytesInKb
 
 	percent := (memTotalKb - memAvailableKb) * 100 / memTotalKb
-	result[percentKey] = float64(percent)
+	result[memPercentKey] = float64(percent)
 
 	swapTotalKb, ok := memInfoData["SwapTotal"]
 	if swapTotalKb > 0 {
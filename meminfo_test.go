@@ -4,7 +4,7 @@ import "log"
 import "testing"
 
 func TestEstimateAvailableMemory(t *testing.T) {
-	r := FileReaderStub{}
+	r := FileReader{procRoot: testProcRoot}
 	o := MeminfoObserver{nil, r, 4096, false, false}
 	memInfoData, err := o.reader.getFloatKeyValuePairs(meminfoFile)
 	if err != nil {
@@ -58,3 +58,18 @@ func TestEstimateAvailableMemory(t *testing.T) {
 		log.Printf("Diff between old-style and new-style MemAvailable: %d kb - %d kb = %d kb", int64(memAvailableEstimatedKb), int64(procAvailMemValue), diff)
 	}
 }
+
+func TestMeminfoRegistersActiveMetrics(t *testing.T) {
+	tr := Tracker{}
+	o := MeminfoObserver{showInactive: true}
+	o.Initialize(&tr, FileReader{procRoot: testProcRoot})
+
+	for _, key := range []string{memTotalKey, memPercentKey, memInactiveFileKey} {
+		if m, ok := tr.metrics[key]; !ok || !m.declared {
+			t.Errorf("Metric '%s' must be declared", key)
+		}
+	}
+	if _, ok := tr.metrics[memReclaimableKey]; ok {
+		t.Errorf("Metric '%s' is disabled, it must not be declared", memReclaimableKey)
+	}
+}
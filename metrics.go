@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+)
+
+type MetricType int
+
+const (
+	Gauge MetricType = iota
+	Counter
+	// bit mask of triggered kernel events, e.g. 'cgroups' or 'psi_trig'
+	Bitmask
+	// discrete pressure level (none, low, medium, critical)
+	Level
+	// series, which no observer has declared
+	Untyped
+)
+
+func (m MetricType) String() string {
+	switch m {
+	case Counter:
+		return "counter"
+	case Bitmask:
+		return "bitmask"
+	case Level:
+		return "level"
+	case Untyped:
+		return "untyped"
+	default:
+		return "gauge"
+	}
+}
+
+const (
+	unitNone             = ""
+	unitMb               = "MB"
+	unitPercent          = "%"
+	unitSeconds          = "s"
+	unitMilliseconds     = "ms"
+	unitFaultsPerSecond  = "faults/s"
+	unitEventsPerSecond  = "events/s"
+	unitFiringsPerMinute = "firings/min"
+)
+
+// Metric describes a value that an observer puts into the Tracker.
+// Labels holds the names of the labels that every series of this metric has,
+// e.g. cgroup path or NUMA node, so several entities can be monitored at once.
+// Unlabeled allows a series without labels next to the labeled ones, e.g. 'cgroups' of the default cgroup
+// next to the ones given with '-cgroup', or the system-wide 'psi_some' next to the per-cgroup ones.
+// Export is the Prometheus name without the namespace (e.g. 'allocated_bytes'),
+// if it's empty, the name is made of Name, Unit and Type.
+type Metric struct {
+	Name      string
+	Type      MetricType
+	Unit      string
+	Help      string
+	Labels    []string
+	Unlabeled bool
+	Export    string
+}
+
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Labels []Label
+
+// MetricValue is one series of a metric with its actual value.
+type MetricValue struct {
+	Column string
+	Metric Metric
+	Labels Labels
+	Value  interface{}
+}
+
+// seriesName is the name of a series in text outputs, e.g. psi_some{cgroup=/workload}
+func seriesName(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label.Name + "=" + label.Value
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelsMatch checks that series labels correspond to the metric declaration
+func (m *Metric) labelsMatch(labels Labels) bool {
+	if len(labels) == 0 && m.Unlabeled {
+		return true
+	}
+	if len(labels) != len(m.Labels) {
+		return false
+	}
+	for i, label := range labels {
+		if label.Name != m.Labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// numericValue converts a tracked value to float64, it returns false for non-numeric values
+func numericValue(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math"
+	"path"
+)
+
+const (
+	oomKillKey       = "oom_kill"
+	oomNewKey        = "oom_new"
+	cgroupOomKillKey = "cg_oom_kill"
+	cgroupOomNewKey  = "cg_oom_new"
+)
+
+// OomObserver tracks OOM killer invocations, which are the ground truth for detectors evaluation
+type OomObserver struct {
+	tracker         *Tracker
+	reader          Reader
+	cgroup          string
+	eventsFile      string
+	noVmstatKills   bool
+	lastKills       int64
+	lastCgroupKills int64
+}
+
+func (o *OomObserver) SetFlags() {
+	flag.StringVar(&o.cgroup, "oomCgroup", "", "cgroup v2 path to track 'oom_kill' in 'memory.events' for, empty for the first '-cgroup' or the cgroup of this process")
+}
+
+func (o *OomObserver) Initialize(t *Tracker, r Reader) {
+	o.tracker = t
+	o.reader = r
+	o.lastKills = -1
+	o.lastCgroupKills = -1
+	o.tracker.register(
+		Metric{Name: oomKillKey, Type: Counter, Help: "Number of processes killed by the OOM killer since boot"},
+		Metric{Name: oomNewKey, Type: Gauge, Help: "Number of processes killed by the OOM killer since the previous update"},
+		Metric{Name: cgroupOomKillKey, Type: Counter, Help: "Number of processes killed by the OOM killer in the cgroup", Labels: []string{"cgroup"}},
+		Metric{Name: cgroupOomNewKey, Type: Gauge, Help: "Number of processes killed by the OOM killer in the cgroup since the previous update", Labels: []string{"cgroup"}},
+	)
+
+	if _, err := r.getIntValue(vmstatPath, oomKillKey); err != nil {
+		// 'oom_kill' appeared in /proc/vmstat in Linux 4.13
+		log.Print("System-wide OOM kills won't be tracked: ", err)
+		o.noVmstatKills = true
+	}
+
+	if mount, ok := cgroupV2Mount(r); ok {
+		if o.cgroup == "" && len(monitoredCgroups) > 0 {
+			o.cgroup = monitoredCgroups[0]
+		} else if o.cgroup == "" {
+			o.cgroup, _ = selfCgroup(r, "")
+		}
+		eventsFile := path.Join(mount, o.cgroup, "memory.events")
+		if _, err := r.getIntValue(eventsFile, oomKillKey); err != nil {
+			log.Print("OOM kills in cgroup won't be tracked: ", err)
+		} else {
+			o.eventsFile = eventsFile
+		}
+	}
+
+	o.process()
+}
+
+func (o *OomObserver) TimerEvent() {
+	o.process()
+}
+
+// newKills returns the number of kills since the previous call, zero for the first one
+func newKills(total int64, last *int64) int64 {
+	var result int64
+	if *last >= 0 && total > *last {
+		result = total - *last
+	}
+	*last = total
+	return result
+}
+
+func (o *OomObserver) process() {
+	if o.noVmstatKills {
+		o.tracker.trackOne(oomKillKey, math.NaN())
+		o.tracker.trackOne(oomNewKey, math.NaN())
+	} else if kills, err := o.reader.getIntValue(vmstatPath, oomKillKey); err != nil {
+		log.Print(err)
+	} else {
+		newValue := newKills(kills, &o.lastKills)
+		if newValue > 0 {
+			log.Printf("OOM killer has killed %d process(es), %d in total", newValue, kills)
+		}
+		o.tracker.trackOne(oomKillKey, kills)
+		o.tracker.trackOne(oomNewKey, newValue)
+	}
+
+	if o.eventsFile == "" {
+		return
+	}
+	labels := Labels{{"cgroup", o.cgroup}}
+	cgroupKills, err := o.reader.getIntValue(o.eventsFile, oomKillKey)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	newValue := newKills(cgroupKills, &o.lastCgroupKills)
+	if newValue > 0 {
+		log.Printf("OOM killer has killed %d process(es) in cgroup '%s', %d in total", newValue, o.cgroup, cgroupKills)
+	}
+	o.tracker.trackLabeled(cgroupOomKillKey, labels, cgroupKills)
+	o.tracker.trackLabeled(cgroupOomNewKey, labels, newValue)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "math"
+import "os"
+import "path/filepath"
+import "testing"
+
+func TestNewKills(t *testing.T) {
+	last := int64(-1)
+	if n := newKills(5, &last); n != 0 {
+		t.Errorf("Kills before the start must not be counted, got %d", n)
+	}
+	if n := newKills(5, &last); n != 0 {
+		t.Errorf("Expected no new kills, got %d", n)
+	}
+	if n := newKills(7, &last); n != 2 {
+		t.Errorf("Expected 2 new kills, got %d", n)
+	}
+}
+
+func TestSelfCgroup(t *testing.T) {
+	r := FileReader{procRoot: testProcRoot}
+	expected := map[string]string{
+		"":       "/workload/batch",
+		"memory": "/workload/batch",
+		"cpu":    "/workload",
+	}
+	for controller, path := range expected {
+		cgroup, err := selfCgroup(r, controller)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cgroup != path {
+			t.Errorf("Wrong cgroup for '%s' controller, expected '%s', got '%s'", controller, path, cgroup)
+		}
+	}
+	if _, err := selfCgroup(r, "hugetlb"); err == nil {
+		t.Error("Missing controller must be reported")
+	}
+}
+
+func TestOomKillsUnavailable(t *testing.T) {
+	procRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(procRoot, "vmstat"), []byte("pgmajfault 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tr := Tracker{}
+	o := OomObserver{}
+	o.Initialize(&tr, FileReader{procRoot: procRoot})
+	o.TimerEvent()
+
+	if !o.noVmstatKills {
+		t.Error("Missing 'oom_kill' counter must be detected on initialization")
+	}
+	if value, ok := snapshotValues(&tr)[oomKillKey]; !ok || !math.IsNaN(value) {
+		t.Errorf("Unavailable '%s' must be reported as NaN, got %v", oomKillKey, value)
+	}
+}
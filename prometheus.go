@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const prometheusNamespace = "memory_pressure"
+
+// HELP lines of the text exposition format escape only backslashes and line feeds,
+// label values escape double quotes too
+var (
+	prometheusHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// PrometheusExporter is a sink, which serves the latest sample over HTTP
+type PrometheusExporter struct {
+	mtx    sync.Mutex
+	latest *Sample
+}
+
+func (e *PrometheusExporter) start(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	log.Printf("Serving Prometheus metrics on http://%s/metrics", address)
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Print("Prometheus exporter failed: ", err)
+		}
+	}()
+}
+
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.mtx.Lock()
+	latest := e.latest
+	e.mtx.Unlock()
+	if latest == nil {
+		http.Error(w, "No data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write(e.render(latest.Values))
+}
+
+func (e *PrometheusExporter) Write(s *Sample) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.latest = s
+	return nil
+}
+
+func (e *PrometheusExporter) Close() error {
+	return nil
+}
+
+// prometheusName converts the metric to base units (bytes, seconds), as Prometheus naming conventions require
+func prometheusName(m *Metric) (name string, scale float64) {
+	const bytesInMb = 1024 * 1024
+
+	name = prometheusNamespace + "_" + m.Name
+	scale = 1
+	switch m.Unit {
+	case unitMb:
+		name += "_bytes"
+		scale = bytesInMb
+	case unitPercent:
+		name += "_percent"
+	case unitSeconds:
+		name += "_seconds"
+	case unitMilliseconds:
+		name += "_seconds"
+		scale = 0.001
+	}
+	if m.Type == Counter {
+		name += "_total"
+	}
+	if m.Export != "" {
+		// exported names are kept stable for the existing dashboards
+		name = prometheusNamespace + "_" + m.Export
+	}
+	return
+}
+
+func prometheusType(m *Metric) string {
+	switch m.Type {
+	case Counter:
+		return "counter"
+	case Gauge, Bitmask, Level:
+		return "gauge"
+	}
+	return "untyped"
+}
+
+func prometheusValue(value interface{}, scale float64) (string, bool) {
+	v, ok := numericValue(value)
+	if !ok {
+		return "", false
+	}
+	if math.IsNaN(v) {
+		return "NaN", true
+	}
+	return strconv.FormatFloat(v*scale, 'g', -1, 64), true
+}
+
+func prometheusLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", label.Name, prometheusLabelEscaper.Replace(label.Value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (e *PrometheusExporter) render(data []MetricValue) []byte {
+	var buf bytes.Buffer
+	printed := make(map[string]bool)
+
+	// series are sorted by name, so all series of one metric go together
+	for _, v := range data {
+		name, scale := prometheusName(&v.Metric)
+		value, ok := prometheusValue(v.Value, scale)
+		if !ok {
+			continue
+		}
+		if !printed[name] {
+			printed[name] = true
+			help := v.Metric.Help
+			if help == "" {
+				help = fmt.Sprintf("'%s' metric", v.Metric.Name)
+			}
+			fmt.Fprintf(&buf, "# HELP %s %s\n", name, prometheusHelpEscaper.Replace(help))
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", name, prometheusType(&v.Metric))
+		}
+		fmt.Fprintf(&buf, "%s%s %s\n", name, prometheusLabels(v.Labels), value)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,95 @@
+package main
+
+import "math"
+import "strings"
+import "testing"
+
+func TestPrometheusRender(t *testing.T) {
+	tr := Tracker{}
+	tr.register(
+		Metric{Name: allocatedKey, Type: Gauge, Unit: unitMb, Help: "Allocated", Export: "allocated_bytes"},
+		Metric{Name: psiSomeKey, Type: Gauge, Unit: unitPercent, Help: "PSI some", Labels: []string{"cgroup"}},
+		Metric{Name: psiFullKey, Type: Gauge, Unit: unitPercent, Help: "PSI full"},
+		Metric{Name: timeKey, Type: Counter, Unit: unitSeconds, Help: "Time"},
+		Metric{Name: "oom_kill", Type: Counter, Help: "OOM kills"},
+	)
+	tr.trackOne(allocatedKey, 2)
+	tr.trackLabeled(psiSomeKey, Labels{{"cgroup", "/a"}}, 1.5)
+	tr.trackLabeled(psiSomeKey, Labels{{"cgroup", "/b"}}, 0.5)
+	tr.trackOne(psiSomeKey, 3.0) // doesn't match the declaration, must be skipped
+	tr.trackOne(psiFullKey, math.NaN())
+	tr.trackOne(timeKey, int64(5))
+	tr.trackOne("oom_kill", int64(3))
+	tr.trackOne("custom", int64(7))
+
+	e := PrometheusExporter{}
+	output := string(e.render(tr.snapshot()))
+
+	expectedLines := []string{
+		"# TYPE memory_pressure_allocated_bytes gauge",
+		"memory_pressure_allocated_bytes 2.097152e+06",
+		"# HELP memory_pressure_psi_some_percent PSI some",
+		"memory_pressure_psi_some_percent{cgroup=\"/a\"} 1.5",
+		"memory_pressure_psi_some_percent{cgroup=\"/b\"} 0.5",
+		"memory_pressure_psi_full_percent NaN",
+		"# TYPE memory_pressure_time_seconds_total counter",
+		"memory_pressure_time_seconds_total 5",
+		"# TYPE memory_pressure_oom_kill_total counter",
+		"memory_pressure_oom_kill_total 3",
+		"# TYPE memory_pressure_custom untyped",
+		"memory_pressure_custom 7",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Line '%s' is missing in the exposition output:\n%s", line, output)
+		}
+	}
+	if strings.Count(output, "# HELP memory_pressure_psi_some_percent") != 1 {
+		t.Errorf("HELP line must be printed once per metric:\n%s", output)
+	}
+	if strings.Contains(output, "memory_pressure_psi_some_percent 3") {
+		t.Errorf("Series with undeclared labels must be skipped:\n%s", output)
+	}
+}
+
+func TestPrometheusHelpEscaper(t *testing.T) {
+	help := prometheusHelpEscaper.Replace("C:\\dir\nnext 'line' \"é\"")
+	expected := `C:\\dir\nnext 'line' "é"`
+	if help != expected {
+		t.Errorf("Wrong escaped help: expected %s, got %s", expected, help)
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	labels := prometheusLabels(Labels{{"cgroup", "/a\\b\n\"é\""}, {"node", "0"}})
+	expected := `{cgroup="/a\\b\n\"é\"",node="0"}`
+	if labels != expected {
+		t.Errorf("Wrong labels: expected %s, got %s", expected, labels)
+	}
+}
+
+// Names of the metrics, which were exported before the registry, must not change
+func TestPrometheusNames(t *testing.T) {
+	tr := Tracker{}
+	tr.prepare()
+	a := Allocator{}
+	a.initialize(&tr, 0)
+	o := MeminfoObserver{showReclaimable: true, showInactive: true}
+	o.Initialize(&tr, FileReader{procRoot: testProcRoot})
+
+	expected := map[string]string{
+		timeKey:            "memory_pressure_elapsed_seconds",
+		allocatedKey:       "memory_pressure_allocated_bytes",
+		memAvailableKey:    "memory_pressure_mem_available_bytes",
+		memPercentKey:      "memory_pressure_mem_used_percent",
+		swapFreeKey:        "memory_pressure_swap_free_bytes",
+		memReclaimableKey:  "memory_pressure_mem_slab_reclaimable_bytes",
+		memInactiveFileKey: "memory_pressure_mem_inactive_file_bytes",
+	}
+	for _, v := range tr.snapshot() {
+		name, _ := prometheusName(&v.Metric)
+		if e, ok := expected[v.Column]; ok && name != e {
+			t.Errorf("Expected '%s' to be exported as '%s', got '%s'", v.Column, e, name)
+		}
+	}
+}
@@ -4,16 +4,30 @@ import (
 	"flag"
 	"log"
 	"math"
+	"path"
 	"strconv"
 	"strings"
 )
 
 const psiMemoryFile = "/proc/pressure/memory"
 
+const (
+	psiSomeKey = "psi_some"
+	psiFullKey = "psi_full"
+)
+
+// psiResources are the resources, which cgroup v2 has pressure files for, e.g. 'cpu.pressure'
+var psiResources = []string{"memory", "cpu", "io"}
+
+// PsiObserver reports the system-wide memory pressure from '/proc/pressure/memory', and, for every
+// monitored cgroup v2, the pressure of the resources from the cgroup's '<resource>.pressure' files
 type PsiObserver struct {
-	tracker   *Tracker
-	reader    Reader
-	avgMetric string
+	tracker         *Tracker
+	reader          Reader
+	avgMetric       string
+	cgroupResources string
+	resources       []string
+	cgroupsMount    string
 }
 
 type PsiValues struct {
@@ -24,11 +38,55 @@ type PsiValues struct {
 func (o *PsiObserver) Initialize(t *Tracker, r Reader) {
 	o.tracker = t
 	o.reader = r
+	o.tracker.register(
+		Metric{Name: psiSomeKey, Type: Gauge, Unit: unitPercent, Help: "Share of time at least one task was stalled on memory", Labels: []string{"cgroup"}, Unlabeled: true, Export: "psi_some_percent"},
+		Metric{Name: psiFullKey, Type: Gauge, Unit: unitPercent, Help: "Share of time all non-idle tasks were stalled on memory", Labels: []string{"cgroup"}, Unlabeled: true, Export: "psi_full_percent"},
+	)
+	if len(monitoredCgroups) > 0 {
+		o.initializeCgroups()
+	}
 	o.process()
 }
 
 func (o *PsiObserver) SetFlags() {
 	flag.StringVar(&o.avgMetric, "psiAvgMetric", "avg10", "metric to use in PSI observer")
+	flag.StringVar(&o.cgroupResources, "psiCgroupResources", "memory", "comma-separated resources ('memory', 'cpu', 'io') to report the pressure of for every monitored cgroup v2")
+}
+
+// psiKeys returns 'some' and 'full' metric names of the resource, the memory ones are 'psi_some' and 'psi_full'
+func psiKeys(resource string) (string, string) {
+	if resource == "memory" {
+		return psiSomeKey, psiFullKey
+	}
+	return "psi_" + resource + "_some", "psi_" + resource + "_full"
+}
+
+func (o *PsiObserver) initializeCgroups() {
+	mount, ok := cgroupV2Mount(o.reader)
+	if !ok {
+		log.Print("Pressure of the cgroups won't be tracked: cgroup v2 is not mounted")
+		return
+	}
+	for _, resource := range strings.Split(o.cgroupResources, ",") {
+		resource = strings.TrimSpace(resource)
+		known := false
+		for _, r := range psiResources {
+			known = known || r == resource
+		}
+		if !known {
+			log.Fatalf("Unknown PSI resource '%s'", resource)
+		}
+		o.resources = append(o.resources, resource)
+		if resource == "memory" {
+			continue
+		}
+		someKey, fullKey := psiKeys(resource)
+		o.tracker.register(
+			Metric{Name: someKey, Type: Gauge, Unit: unitPercent, Help: "Share of time at least one task of the cgroup was stalled on " + resource, Labels: []string{"cgroup"}},
+			Metric{Name: fullKey, Type: Gauge, Unit: unitPercent, Help: "Share of time all non-idle tasks of the cgroup were stalled on " + resource, Labels: []string{"cgroup"}},
+		)
+	}
+	o.cgroupsMount = mount
 }
 
 func (o *PsiObserver) TimerEvent() {
@@ -49,34 +107,48 @@ func (o *PsiObserver) parsePsiValue(text string, key string) float64 {
 	return result
 }
 
-func (o *PsiObserver) getPsiValues() (*PsiValues, error) {
+func (o *PsiObserver) getPsiValues(filename string) (*PsiValues, error) {
 	var values PsiValues
 
-	psiSome, err := o.reader.getTextValue(psiMemoryFile, "some")
+	psiSome, err := o.reader.getTextValue(filename, "some")
 	if err != nil {
 		return nil, err
 	}
 	values.someAvg = o.parsePsiValue(psiSome, o.avgMetric)
 
-	psiFull, err := o.reader.getTextValue(psiMemoryFile, "full")
-	if err != nil {
-		return nil, err
+	// there is no 'full' line in 'cpu.pressure' before Linux 5.13
+	values.fullAvg = math.NaN()
+	if psiFull, err := o.reader.getTextValue(filename, "full"); err == nil {
+		values.fullAvg = o.parsePsiValue(psiFull, o.avgMetric)
 	}
-	values.fullAvg = o.parsePsiValue(psiFull, o.avgMetric)
 
 	return &values, nil
 }
 
 func (o *PsiObserver) process() {
-	const someAvgKey string = "psi_some"
-	const fullAvgKey string = "psi_full"
-
 	result := make(map[string]interface{})
 
-	values, err := o.getPsiValues()
+	values, err := o.getPsiValues(psiMemoryFile)
 	if err == nil {
-		result[someAvgKey] = values.someAvg
-		result[fullAvgKey] = values.fullAvg
+		result[psiSomeKey] = values.someAvg
+		result[psiFullKey] = values.fullAvg
 	}
 	o.tracker.track(&result)
+
+	if o.cgroupsMount == "" {
+		return
+	}
+	for _, cgroup := range monitoredCgroups {
+		labels := Labels{{"cgroup", cgroup}}
+		for _, resource := range o.resources {
+			someKey, fullKey := psiKeys(resource)
+			// the cgroup may be removed, its pressure is unknown then
+			values, err := o.getPsiValues(path.Join(o.cgroupsMount, cgroup, resource+".pressure"))
+			if err != nil {
+				values = &PsiValues{math.NaN(), math.NaN()}
+			}
+			o.tracker.trackLabeled(someKey, labels, values.someAvg)
+			o.tracker.trackLabeled(fullKey, labels, values.fullAvg)
+		}
+	}
 }
@@ -0,0 +1,30 @@
+package main
+
+import "math"
+import "testing"
+
+func TestPsiObserverCgroups(t *testing.T) {
+	monitoredCgroups = cgroupList{"/workload/batch", "/removed"}
+	defer func() { monitoredCgroups = nil }()
+	tr := Tracker{}
+	o := PsiObserver{avgMetric: "avg10", cgroupResources: "memory,cpu"}
+	o.Initialize(&tr, FileReader{procRoot: testProcRoot, sysRoot: testSysRoot})
+
+	values := snapshotValues(&tr)
+	expected := map[string]float64{
+		"psi_some":                             0,
+		"psi_some{cgroup=/workload/batch}":     12.5,
+		"psi_full{cgroup=/workload/batch}":     7.25,
+		"psi_cpu_some{cgroup=/workload/batch}": 30,
+	}
+	for column, value := range expected {
+		if values[column] != value {
+			t.Errorf("Expected %v for '%s', got %v", value, column, values[column])
+		}
+	}
+	for _, column := range []string{"psi_cpu_full{cgroup=/workload/batch}", "psi_some{cgroup=/removed}"} {
+		if value, ok := values[column]; !ok || !math.IsNaN(value) {
+			t.Errorf("Expected NaN for '%s', got %v", column, values[column])
+		}
+	}
+}
@@ -2,67 +2,424 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 const (
-	psiPath     = "/proc/pressure/memory"
-	pressureKey = "psi_trig"
+	psiPath        = "/proc/pressure/memory"
+	psiTriggersKey = "psi_trig"
+	// named triggers are reported as 'psi_trig_<name>'
+	psiNamedTriggerPrefix = psiTriggersKey + "_"
 )
 
+var psiLevelNames = []string{"medium", "critical"}
+
+// suffixes of the triggers statistics metrics, e.g. 'psi_trig_medium_fired'
+const (
+	psiStatFired      = "_fired"
+	psiStatRate       = "_rate"
+	psiStatLastFired  = "_last"
+	psiStatSinceFirst = "_since_first"
+)
+
+// psiRateWindow is the window of the triggers firing rate
+const psiRateWindow = time.Minute
+
+// psiStatsInterval is how often the statistics are updated, while no trigger fires
+const psiStatsInterval = time.Second
+
+// psiTriggerSpec is a trigger definition, the target is either the system-wide or a cgroup's memory pressure file
+type psiTriggerSpec struct {
+	name    string
+	cgroup  string // empty for the system-wide trigger
+	kernel  string // e.g. 'some 150000 1000000'
+	bit     int    // bit in 'psi_trig' mask: 0 - medium, 1 - critical, -1 for a named trigger
+	timeout time.Duration
+}
+
+// parsePsiThreshold converts 'some|full', stall and window fields of the spec to the kernel trigger string
+func parsePsiThreshold(spec string, fields []string) (string, error) {
+	if fields[0] != "some" && fields[0] != "full" {
+		return "", fmt.Errorf("PSI trigger '%s' must be either 'some' or 'full', got '%s'", spec, fields[0])
+	}
+	stall, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("PSI trigger '%s': %v", spec, err)
+	}
+	window, err := time.ParseDuration(fields[2])
+	if err != nil {
+		return "", fmt.Errorf("PSI trigger '%s': %v", spec, err)
+	}
+	if stall <= 0 || stall > window {
+		return "", fmt.Errorf("PSI trigger '%s': stall time must be positive and not greater than the window", spec)
+	}
+	return fmt.Sprintf("%s %d %d", fields[0], stall.Microseconds(), window.Microseconds()), nil
+}
+
+func parsePsiCgroup(cgroup string) (string, error) {
+	if !strings.HasPrefix(cgroup, "/") {
+		return "", fmt.Errorf("Cgroup path must start with '/', got '%s'", cgroup)
+	}
+	return path.Clean(cgroup), nil
+}
+
+// parsePsiCgroupTrigger parses 'medium|critical:cgroup:some|full:stall:window', e.g. 'critical:/workload:full:100ms:1s'
+func parsePsiCgroupTrigger(spec string) (*psiTriggerSpec, error) {
+	fields := strings.Split(spec, ":")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("PSI trigger '%s' is expected as 'medium|critical:cgroup:some|full:stall:window'", spec)
+	}
+	result := &psiTriggerSpec{name: fields[0], bit: -1}
+	for bit, name := range psiLevelNames {
+		if fields[0] == name {
+			result.bit = bit
+		}
+	}
+	if result.bit < 0 {
+		return nil, fmt.Errorf("PSI trigger '%s' must be either 'medium' or 'critical', got '%s'", spec, fields[0])
+	}
+	var err error
+	if result.cgroup, err = parsePsiCgroup(fields[1]); err != nil {
+		return nil, err
+	}
+	if result.kernel, err = parsePsiThreshold(spec, fields[2:]); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+var psiTriggerNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// parsePsiTrigger parses 'name[:cgroup]:some|full:stall:window[:timeout]', e.g. 'warn:some:50ms:1s'
+// or 'warn:/workload:some:50ms:1s', the trigger is system-wide without the cgroup
+func parsePsiTrigger(spec string) (*psiTriggerSpec, error) {
+	fields := strings.Split(spec, ":")
+	result := &psiTriggerSpec{name: fields[0], bit: -1}
+	if len(fields) > 1 && strings.HasPrefix(fields[1], "/") {
+		cgroup, err := parsePsiCgroup(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		result.cgroup = cgroup
+		fields = append(fields[:1], fields[2:]...)
+	}
+	if len(fields) != 4 && len(fields) != 5 {
+		return nil, fmt.Errorf("PSI trigger '%s' is expected as 'name[:cgroup]:some|full:stall:window[:timeout]'", spec)
+	}
+	if !psiTriggerNameRe.MatchString(fields[0]) {
+		return nil, fmt.Errorf("PSI trigger name '%s' must be alphanumeric", fields[0])
+	}
+	var err error
+	if result.kernel, err = parsePsiThreshold(spec, fields[1:4]); err != nil {
+		return nil, err
+	}
+	if len(fields) == 5 {
+		if result.timeout, err = time.ParseDuration(fields[4]); err != nil {
+			return nil, fmt.Errorf("PSI trigger '%s': %v", spec, err)
+		}
+	}
+	return result, nil
+}
+
+func psiTriggerSpecsString(specs []*psiTriggerSpec) string {
+	var result []string
+	for _, s := range specs {
+		result = append(result, s.name+":"+psiTriggerTarget(s.cgroup))
+	}
+	return strings.Join(result, ",")
+}
+
+// appendPsiTriggerSpec rejects a trigger, which is already defined for the same target,
+// but the same trigger can be set up on several cgroups
+func appendPsiTriggerSpec(specs []*psiTriggerSpec, spec *psiTriggerSpec) ([]*psiTriggerSpec, error) {
+	for _, s := range specs {
+		if s.name == spec.name && s.cgroup == spec.cgroup {
+			return nil, fmt.Errorf("PSI trigger '%s' of '%s' is already defined", spec.name, psiTriggerTarget(spec.cgroup))
+		}
+	}
+	return append(specs, spec), nil
+}
+
+// psiTriggerSpecList is a repeatable '-psiCgroupTrigger' command-line option
+type psiTriggerSpecList []*psiTriggerSpec
+
+func (l *psiTriggerSpecList) String() string {
+	return psiTriggerSpecsString(*l)
+}
+
+func (l *psiTriggerSpecList) Set(value string) error {
+	spec, err := parsePsiCgroupTrigger(value)
+	if err != nil {
+		return err
+	}
+	*l, err = appendPsiTriggerSpec(*l, spec)
+	return err
+}
+
+// psiTriggerList is a repeatable '-psiTrigger' command-line option
+type psiTriggerList []*psiTriggerSpec
+
+func (l *psiTriggerList) String() string {
+	return psiTriggerSpecsString(*l)
+}
+
+func (l *psiTriggerList) Set(value string) error {
+	spec, err := parsePsiTrigger(value)
+	if err != nil {
+		return err
+	}
+	if err = checkPsiTriggerName(*l, spec.name); err != nil {
+		return err
+	}
+	*l, err = appendPsiTriggerSpec(*l, spec)
+	return err
+}
+
+// psiTriggerKeys returns the names of the metrics, which are derived from the trigger name
+func psiTriggerKeys(name string) []string {
+	prefix := psiNamedTriggerPrefix + name
+	return []string{prefix, prefix + psiStatFired, prefix + psiStatRate, prefix + psiStatLastFired, prefix + psiStatSinceFirst}
+}
+
+// checkPsiTriggerName rejects the names of the medium and the critical triggers
+// and the names, which metrics would collide with the metrics of the other triggers
+func checkPsiTriggerName(specs []*psiTriggerSpec, name string) error {
+	others := append([]string{}, psiLevelNames...)
+	for _, other := range others {
+		if name == other {
+			return fmt.Errorf("PSI trigger name '%s' is reserved", name)
+		}
+	}
+	for _, s := range specs {
+		others = append(others, s.name)
+	}
+	for _, other := range others {
+		if other == name {
+			continue
+		}
+		for _, otherKey := range psiTriggerKeys(other) {
+			for _, key := range psiTriggerKeys(name) {
+				if key == otherKey {
+					return fmt.Errorf("Metric '%s' of PSI trigger '%s' collides with the metrics of '%s' trigger", key, name, other)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// psiTrigger is a trigger on the system-wide or a cgroup's memory pressure file,
+// it's active until it doesn't fire again for its timeout
+type psiTrigger struct {
+	spec       *psiTriggerSpec
+	cgroup     string // empty for the system-wide trigger
+	fd         int
+	lastFired  time.Time
+	active     bool
+	fired      int64
+	firstFired time.Time
+	recent     []time.Time // firings within the rate window
+}
+
+// fire counts the epoll wakeup of the trigger
+func (t *psiTrigger) fire(now time.Time) {
+	t.lastFired = now
+	t.fired++
+	if t.firstFired.IsZero() {
+		t.firstFired = now
+	}
+	t.recent = append(t.recent, now)
+}
+
+// rate returns the number of firings within the last rate window
+func (t *psiTrigger) rate(now time.Time) int {
+	i := 0
+	for i < len(t.recent) && now.Sub(t.recent[i]) >= psiRateWindow {
+		i++
+	}
+	t.recent = t.recent[i:]
+	return len(t.recent)
+}
+
+func (t *psiTrigger) key() string {
+	return psiNamedTriggerPrefix + t.spec.name
+}
+
+// PsiTrigObserver sets up PSI triggers on '/proc/pressure/memory' and, optionally, on 'memory.pressure'
+// of cgroups v2, one epoll loop waits for the triggers of all of them
 type PsiTrigObserver struct {
 	tracker             *Tracker
+	reader              Reader
 	notifyChan          chan bool
-	mediumEventFd       int
-	criticalEventFd     int
-	oldPressure         int
+	mtx                 sync.Mutex
+	triggers            map[int32]*psiTrigger
+	oldPressure         map[string]int
 	timeout             int
 	mediumLevelString   string
 	criticalLevelString string
+	cgroupTriggers      psiTriggerSpecList
+	named               psiTriggerList
+	stats               bool
 }
 
 func (o *PsiTrigObserver) SetFlags() {
 	flag.StringVar(&o.mediumLevelString, "psiMediumTrigger", "some 150000 1000000", "PSI medium trigger string")
 	flag.StringVar(&o.criticalLevelString, "psiCriticalTrigger", "full 100000 1000000", "PSI critical trigger string")
 	flag.IntVar(&o.timeout, "psiTrigTimeout", 5, "PSI trigger timeout")
+	flag.Var(&o.cgroupTriggers, "psiCgroupTrigger", "PSI trigger on 'memory.pressure' of a cgroup v2 as 'medium|critical:cgroup:some|full:stall:window', e.g. 'critical:/workload:full:100ms:1s', can be repeated")
+	flag.Var(&o.named, "psiTrigger", "named PSI trigger as 'name[:cgroup]:some|full:stall:window[:timeout]', e.g. 'warn:some:50ms:1s', reported as 'psi_trig_<name>', can be repeated, the timeout is '-psiTrigTimeout' by default")
+	flag.BoolVar(&o.stats, "psiTrigStats", false, "report firing statistics of every PSI trigger: firings count, firings within the last minute, time of the last firing and time since the first one")
 }
 
 func (o *PsiTrigObserver) Initialize(t *Tracker, r Reader, c chan bool) {
 	o.tracker = t
+	o.reader = r
 	o.notifyChan = c
-	var err error
+	o.triggers = make(map[int32]*psiTrigger)
+	o.oldPressure = make(map[string]int)
 
-	o.mediumEventFd, err = o.initializeFd(prepareLevelString(o.mediumLevelString))
-	if err != nil {
-		log.Print("Error while creating medium PSI trigger: ", err)
-		return
+	timeout := time.Duration(o.timeout) * time.Second
+	specs := []*psiTriggerSpec{
+		{name: "medium", kernel: o.mediumLevelString, bit: 0},
+		{name: "critical", kernel: o.criticalLevelString, bit: 1},
 	}
+	specs = append(specs, o.cgroupTriggers...)
+	specs = append(specs, o.named...)
 
-	o.criticalEventFd, err = o.initializeFd(prepareLevelString(o.criticalLevelString))
-	if err != nil {
-		log.Print("Error while creating critical PSI trigger: ", err)
+	_, cgroupsMounted := cgroupV2Mount(r)
+	targets := make(map[string][]*psiTriggerSpec)
+	for _, spec := range specs {
+		if spec.timeout == 0 {
+			spec.timeout = timeout
+		}
+		if spec.cgroup != "" && !cgroupsMounted {
+			log.Printf("PSI trigger '%s' of '%s' cgroup won't be set up: cgroup v2 is not mounted", spec.name, spec.cgroup)
+			continue
+		}
+		targets[spec.cgroup] = append(targets[spec.cgroup], spec)
+	}
+
+	for cgroup, specs := range targets {
+		o.initializeTarget(cgroup, specs)
+	}
+	if len(o.triggers) == 0 {
 		return
 	}
 
-	o.tracker.trackOne(pressureKey, 0)
+	o.tracker.register(Metric{Name: psiTriggersKey, Type: Bitmask, Help: "PSI triggers bit mask (critical - medium)", Labels: []string{"cgroup"}, Unlabeled: true, Export: "psi_trigger_state"})
+	registered := make(map[string]bool)
+	for _, spec := range o.named {
+		if !registered[spec.name] {
+			o.tracker.register(Metric{Name: psiNamedTriggerPrefix + spec.name, Type: Gauge, Help: fmt.Sprintf("1 if '%s' PSI trigger has fired within its timeout, 0 otherwise", spec.name), Labels: []string{"cgroup"}, Unlabeled: true})
+			registered[spec.name] = true
+		}
+	}
+	if o.stats {
+		for _, spec := range specs {
+			o.registerStats(spec)
+		}
+	}
+	for cgroup := range o.oldPressure {
+		o.tracker.trackLabeled(psiTriggersKey, psiTriggerLabels(cgroup), 0)
+	}
+	for _, t := range o.triggers {
+		if t.spec.bit < 0 {
+			o.tracker.trackLabeled(t.key(), psiTriggerLabels(t.cgroup), 0)
+		}
+		if o.stats {
+			o.trackStats(t, time.Now())
+		}
+	}
 	go o.startCheckingPressure()
 }
 
+func (o *PsiTrigObserver) registerStats(spec *psiTriggerSpec) {
+	prefix := psiNamedTriggerPrefix + spec.name
+	labels := []string{"cgroup"}
+	o.tracker.register(
+		Metric{Name: prefix + psiStatFired, Type: Counter, Help: fmt.Sprintf("Number of '%s' PSI trigger firings", spec.name), Labels: labels, Unlabeled: true},
+		Metric{Name: prefix + psiStatRate, Type: Gauge, Unit: unitFiringsPerMinute, Help: fmt.Sprintf("Number of '%s' PSI trigger firings within the last minute", spec.name), Labels: labels, Unlabeled: true},
+		Metric{Name: prefix + psiStatLastFired, Type: Gauge, Unit: unitSeconds, Help: fmt.Sprintf("Time of the last '%s' PSI trigger firing since the process start, NaN if it has never fired", spec.name), Labels: labels, Unlabeled: true},
+		Metric{Name: prefix + psiStatSinceFirst, Type: Gauge, Unit: unitSeconds, Help: fmt.Sprintf("Time since the first '%s' PSI trigger firing, NaN if it has never fired", spec.name), Labels: labels, Unlabeled: true},
+	)
+}
+
+// trackStats reports the firing statistics of the trigger, times are in seconds as 'time' is
+func (o *PsiTrigObserver) trackStats(t *psiTrigger, now time.Time) {
+	prefix := psiNamedTriggerPrefix + t.spec.name
+	labels := psiTriggerLabels(t.cgroup)
+	last, sinceFirst := math.NaN(), math.NaN()
+	if t.fired > 0 {
+		last = t.lastFired.Sub(time.Unix(o.tracker.startTime, 0)).Seconds()
+		sinceFirst = now.Sub(t.firstFired).Seconds()
+	}
+	o.tracker.trackLabeled(prefix+psiStatFired, labels, t.fired)
+	o.tracker.trackLabeled(prefix+psiStatRate, labels, t.rate(now))
+	o.tracker.trackLabeled(prefix+psiStatLastFired, labels, last)
+	o.tracker.trackLabeled(prefix+psiStatSinceFirst, labels, sinceFirst)
+}
+
+func psiTriggerLabels(cgroup string) Labels {
+	if cgroup == "" {
+		return nil
+	}
+	return Labels{{"cgroup", cgroup}}
+}
+
+// initializeTarget creates the triggers on the pressure file of the target, either all or none
+func (o *PsiTrigObserver) initializeTarget(cgroup string, specs []*psiTriggerSpec) {
+	filename := psiPath
+	if cgroup != "" {
+		mount, _ := cgroupV2Mount(o.reader)
+		filename = path.Join(mount, cgroup, "memory.pressure")
+	}
+	var triggers []*psiTrigger
+	for _, spec := range specs {
+		fd, err := o.initializeFd(filename, prepareLevelString(spec.kernel))
+		if err != nil {
+			log.Printf("Error while creating %s PSI trigger on '%s': %v", spec.name, filename, err)
+			for _, t := range triggers {
+				syscall.Close(t.fd)
+			}
+			return
+		}
+		triggers = append(triggers, &psiTrigger{spec: spec, cgroup: cgroup, fd: fd})
+	}
+	for _, t := range triggers {
+		o.triggers[int32(t.fd)] = t
+	}
+	o.oldPressure[cgroup] = 0
+}
+
 func (o *PsiTrigObserver) Close() error {
-	syscall.Close(o.mediumEventFd)
-	syscall.Close(o.criticalEventFd)
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	for fd := range o.triggers {
+		syscall.Close(int(fd))
+	}
 	// closing a file descriptor cause it to be removed from all epoll interest lists
 	// so we don't care about EPOLL_CTL_DEL
 	return nil
 }
 
-func (o *PsiTrigObserver) initializeFd(level []byte) (int, error) {
-	fd, err := syscall.Open(psiPath, syscall.O_RDWR|syscall.O_NONBLOCK, 0777)
-	if err == nil {
-		_, err = syscall.Write(fd, level)
+func (o *PsiTrigObserver) initializeFd(filename string, level []byte) (int, error) {
+	fd, err := syscall.Open(o.reader.resolvePath(filename), syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0777)
+	if err != nil {
+		return -1, err
 	}
-	return fd, err
+	if _, err = syscall.Write(fd, level); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
 }
 
 func prepareLevelString(level string) []byte {
@@ -79,58 +436,147 @@ func setupPolling(epfd int, fd int) error {
 }
 
 func (o *PsiTrigObserver) startCheckingPressure() {
-	var events [2]syscall.EpollEvent
-	epfd, err := syscall.EpollCreate1(0)
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
 	if err != nil {
 		log.Print("epoll_create1 failed: ", err)
 		return
 	}
 	defer syscall.Close(epfd)
 
-	if err = setupPolling(epfd, o.mediumEventFd); err != nil {
-		log.Print("Failed to setup epoll(): ", err)
-	}
-
-	if err = setupPolling(epfd, o.criticalEventFd); err != nil {
-		log.Print("Failed to setup epoll(): ", err)
+	o.mtx.Lock()
+	for fd := range o.triggers {
+		if err = setupPolling(epfd, int(fd)); err != nil {
+			log.Print("Failed to setup epoll(): ", err)
+		}
 	}
+	events := make([]syscall.EpollEvent, len(o.triggers))
+	o.mtx.Unlock()
 
-	for {
-		var criticalLevel bool
-		var mediumLevel bool
-		nevents, err := syscall.EpollWait(epfd, events[:], int(o.timeout*1000))
+	// wait forever, until a trigger fires, or until the next active trigger expires
+	wait := time.Duration(-1)
+	for o.hasTriggers() {
+		msec := -1
+		if wait >= 0 {
+			msec = int(math.Ceil(float64(wait) / float64(time.Millisecond)))
+		}
+		nevents, err := syscall.EpollWait(epfd, events, msec)
+		if err == syscall.EINTR {
+			continue
+		}
 		if err != nil {
 			log.Print("epoll_wait failed: ", err)
 			break
 		}
+		now := time.Now()
+		o.handleEvents(epfd, events[:nevents], now)
+		wait = o.update(now)
+	}
+}
 
-		for ev := 0; ev < nevents; ev++ {
-			if int(events[ev].Fd) == o.criticalEventFd {
-				criticalLevel = true
-			}
-			if int(events[ev].Fd) == o.mediumEventFd {
-				mediumLevel = true
-			}
+func (o *PsiTrigObserver) hasTriggers() bool {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return len(o.triggers) > 0
+}
+
+// handleEvents marks the fired triggers, the triggers with errors (e.g. the cgroup is removed) are closed
+// with the other triggers of the target, otherwise epoll would report them again and again
+func (o *PsiTrigObserver) handleEvents(epfd int, events []syscall.EpollEvent, now time.Time) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	removed := make(map[string]bool)
+	for _, event := range events {
+		t, ok := o.triggers[event.Fd]
+		if !ok {
+			continue
+		}
+		if event.Events&(syscall.EPOLLERR|syscall.EPOLLHUP) != 0 {
+			removed[t.cgroup] = true
+			continue
 		}
-		o.reportPressureIfChanged(criticalLevel, mediumLevel)
+		t.fire(now)
+	}
+	for fd, t := range o.triggers {
+		if !removed[t.cgroup] {
+			continue
+		}
+		syscall.EpollCtl(epfd, syscall.EPOLL_CTL_DEL, int(fd), nil)
+		syscall.Close(int(fd))
+		delete(o.triggers, fd)
+		if t.active && t.spec.bit < 0 {
+			o.tracker.trackLabeled(t.key(), psiTriggerLabels(t.cgroup), 0)
+		}
+	}
+	for cgroup := range removed {
+		log.Printf("PSI triggers of '%s' cgroup have failed, the cgroup is removed", cgroup)
 	}
 }
 
-func (o *PsiTrigObserver) reportPressureIfChanged(criticalLevel bool, mediumLevel bool) {
-	newPressure := 0
-	if criticalLevel {
-		newPressure |= 2
+// update expires every trigger on its own timeout and reports the changes, it returns the time
+// until the next expiry or statistics update, -1 if there are no active triggers and no statistics
+func (o *PsiTrigObserver) update(now time.Time) time.Duration {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	changed := false
+	next := time.Duration(-1)
+	pressure := make(map[string]int)
+	for cgroup := range o.oldPressure {
+		pressure[cgroup] = 0
+	}
+	for _, t := range o.triggers {
+		left := t.spec.timeout - now.Sub(t.lastFired)
+		active := !t.lastFired.IsZero() && left > 0
+		if active && (next < 0 || left < next) {
+			next = left
+		}
+		if active && t.spec.bit >= 0 {
+			pressure[t.cgroup] |= 1 << uint(t.spec.bit)
+		}
+		if o.stats {
+			o.trackStats(t, now)
+		}
+		if active == t.active {
+			continue
+		}
+		t.active = active
+		if t.spec.bit < 0 {
+			value := 0
+			if active {
+				value = 1
+				log.Printf("PSI trigger '%s' of '%s' has fired", t.spec.name, psiTriggerTarget(t.cgroup))
+			} else {
+				log.Printf("PSI trigger '%s' of '%s' has expired", t.spec.name, psiTriggerTarget(t.cgroup))
+			}
+			o.tracker.trackLabeled(t.key(), psiTriggerLabels(t.cgroup), value)
+			changed = true
+		}
 	}
-	if mediumLevel {
-		newPressure |= 1
+	for cgroup, newPressure := range pressure {
+		if newPressure != o.oldPressure[cgroup] {
+			o.tracker.trackLabeled(psiTriggersKey, psiTriggerLabels(cgroup), newPressure)
+			o.oldPressure[cgroup] = newPressure
+			changed = true
+		}
 	}
-	if newPressure != o.oldPressure {
-		o.tracker.trackOne(pressureKey, newPressure)
+
+	if changed {
 		// non-nlocking notification sending
 		select {
-			case o.notifyChan <- true:
-			default:
+		case o.notifyChan <- true:
+		default:
 		}
-		o.oldPressure = newPressure
 	}
+	if o.stats && (next < 0 || next > psiStatsInterval) {
+		next = psiStatsInterval
+	}
+	return next
+}
+
+func psiTriggerTarget(cgroup string) string {
+	if cgroup == "" {
+		return psiPath
+	}
+	return cgroup
 }
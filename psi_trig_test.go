@@ -0,0 +1,176 @@
+package main
+
+import "syscall"
+import "testing"
+import "time"
+
+func TestParsePsiTrigger(t *testing.T) {
+	spec, err := parsePsiTrigger("warn:some:50ms:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.name != "warn" || spec.kernel != "some 50000 1000000" || spec.bit != -1 || spec.timeout != 0 {
+		t.Errorf("Wrong trigger %+v", spec)
+	}
+	if spec, err = parsePsiTrigger("stall:full:200ms:2s:10s"); err != nil || spec.timeout != 10*time.Second || spec.cgroup != "" {
+		t.Errorf("Expected system-wide trigger with 10s timeout, got %+v (%v)", spec, err)
+	}
+	if spec, err = parsePsiTrigger("warn:/workload:some:50ms:1s:3s"); err != nil || spec.cgroup != "/workload" || spec.kernel != "some 50000 1000000" || spec.timeout != 3*time.Second {
+		t.Errorf("Expected '/workload' trigger with 3s timeout, got %+v (%v)", spec, err)
+	}
+
+	for _, s := range []string{"warn:some:50ms", "warn:any:50ms:1s", "warn:some:2s:1s", "w-1:some:50ms:1s", "warn:some:50:1s", "warn:/workload:some:50ms"} {
+		if _, err := parsePsiTrigger(s); err == nil {
+			t.Errorf("Trigger '%s' must be rejected", s)
+		}
+	}
+
+	var l psiTriggerList
+	l.Set("warn:some:50ms:1s")
+	if err := l.Set("warn:full:50ms:1s"); err == nil {
+		t.Error("Duplicate trigger name must be rejected")
+	}
+	if err := l.Set("warn:/workload:full:50ms:1s"); err != nil {
+		t.Errorf("The same trigger of a cgroup must be accepted, got %v", err)
+	}
+	for _, name := range psiLevelNames {
+		if err := l.Set(name + ":some:50ms:1s"); err == nil {
+			t.Errorf("Reserved trigger name '%s' must be rejected", name)
+		}
+	}
+	// statistics metrics of one trigger must not be confused with another trigger
+	for _, s := range []string{"warn_fired:some:50ms:1s", "medium_rate:some:50ms:1s"} {
+		if err := l.Set(s); err == nil {
+			t.Errorf("Trigger '%s' must be rejected as colliding", s)
+		}
+	}
+	l = nil
+	l.Set("stall_last:full:50ms:1s")
+	if err := l.Set("stall:full:50ms:1s"); err == nil {
+		t.Error("Trigger colliding with the statistics of the other one must be rejected")
+	}
+}
+
+func TestParsePsiCgroupTrigger(t *testing.T) {
+	spec, err := parsePsiCgroupTrigger("critical:/workload/:full:100ms:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.cgroup != "/workload" || spec.bit != 1 || spec.kernel != "full 100000 1000000" {
+		t.Errorf("Wrong trigger %+v", spec)
+	}
+
+	for _, s := range []string{"critical:/workload:full:100ms", "high:/workload:full:100ms:1s", "medium:workload:some:50ms:1s", "medium:/workload:any:50ms:1s", "medium:/workload:some:2s:1s", "medium:/workload:some:50:1s"} {
+		if _, err := parsePsiCgroupTrigger(s); err == nil {
+			t.Errorf("Trigger '%s' must be rejected", s)
+		}
+	}
+
+	var l psiTriggerSpecList
+	l.Set("medium:/workload:some:50ms:1s")
+	if err := l.Set("medium:/workload:full:50ms:1s"); err == nil {
+		t.Error("Duplicate trigger of the cgroup must be rejected")
+	}
+	if err := l.Set("medium:/other:some:50ms:1s"); err != nil {
+		t.Errorf("The same level of another cgroup must be accepted, got %v", err)
+	}
+}
+
+func TestPsiTriggersEvents(t *testing.T) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(epfd)
+
+	tr := Tracker{}
+	o := PsiTrigObserver{
+		tracker:     &tr,
+		notifyChan:  make(chan bool, 1),
+		triggers:    make(map[int32]*psiTrigger),
+		oldPressure: map[string]int{"": 0, "/removed": 0},
+	}
+	specs := []*psiTriggerSpec{
+		{name: "medium", bit: 0, timeout: 5 * time.Second},
+		{name: "critical", bit: 1, timeout: 5 * time.Second},
+		{name: "warn", bit: -1, timeout: 2 * time.Second},
+	}
+	fds := make(map[string][]int)
+	for cgroup := range o.oldPressure {
+		for _, spec := range specs {
+			var p [2]int
+			if err := syscall.Pipe2(p[:], syscall.O_CLOEXEC); err != nil {
+				t.Fatal(err)
+			}
+			defer syscall.Close(p[1])
+			fds[cgroup] = append(fds[cgroup], p[0])
+			o.triggers[int32(p[0])] = &psiTrigger{spec: spec, cgroup: cgroup, fd: p[0]}
+		}
+	}
+	defer o.Close()
+
+	start := time.Unix(1000, 0)
+	o.handleEvents(epfd, []syscall.EpollEvent{
+		{Events: syscall.EPOLLPRI, Fd: int32(fds[""][1])},
+		{Events: syscall.EPOLLPRI, Fd: int32(fds[""][2])},
+		{Events: syscall.EPOLLPRI, Fd: int32(fds["/removed"][0])},
+		{Events: syscall.EPOLLERR | syscall.EPOLLHUP, Fd: int32(fds["/removed"][1])},
+	}, start)
+	if wait := o.update(start); wait != 2*time.Second {
+		t.Errorf("Expected to wait for 'warn' trigger expiry in 2s, got %v", wait)
+	}
+	v := snapshotValues(&tr)
+	if _, ok := v["psi_trig{cgroup=/removed}"]; ok || v["psi_trig"] != 2 || v["psi_trig_warn"] != 1 {
+		t.Errorf("Expected critical system-wide pressure with 'warn' fired, got %v", v)
+	}
+	if len(o.triggers) != 3 {
+		t.Errorf("Triggers of the removed cgroup must be closed, got %d triggers", len(o.triggers))
+	}
+
+	// every trigger expires on its own timeout
+	if wait := o.update(start.Add(3 * time.Second)); wait != 2*time.Second {
+		t.Errorf("Expected to wait for 'critical' trigger expiry in 2s, got %v", wait)
+	}
+	v = snapshotValues(&tr)
+	if v["psi_trig"] != 2 || v["psi_trig_warn"] != 0 {
+		t.Errorf("Expected 'warn' trigger to expire before 'critical' one, got %v", v)
+	}
+	if wait := o.update(start.Add(5 * time.Second)); wait != -1 {
+		t.Errorf("Expected to wait for events only, got %v", wait)
+	}
+	if v = snapshotValues(&tr); v["psi_trig"] != 0 {
+		t.Errorf("Expected no pressure after the timeout, got %v", v)
+	}
+}
+
+func TestPsiTriggerStats(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tr := Tracker{startTime: start.Unix() - 5}
+	o := PsiTrigObserver{tracker: &tr, notifyChan: make(chan bool, 1), stats: true, oldPressure: map[string]int{"/workload": 0}}
+	trigger := &psiTrigger{spec: &psiTriggerSpec{name: "warn", bit: -1, timeout: 2 * time.Second}, cgroup: "/workload", fd: -1}
+	o.triggers = map[int32]*psiTrigger{-1: trigger}
+
+	if wait := o.update(start); wait != time.Second {
+		t.Errorf("Statistics must be updated every second, got %v", wait)
+	}
+	if v := snapshotValues(&tr); v["psi_trig_warn_fired{cgroup=/workload}"] != 0 || v["psi_trig_warn_rate{cgroup=/workload}"] != 0 {
+		t.Errorf("Expected no firings, got %v", v)
+	}
+
+	for _, elapsed := range []time.Duration{0, 20 * time.Second, 70 * time.Second} {
+		trigger.fire(start.Add(elapsed))
+	}
+	o.update(start.Add(75 * time.Second))
+	v := snapshotValues(&tr)
+	expected := map[string]float64{
+		"psi_trig_warn_fired{cgroup=/workload}":       3,
+		"psi_trig_warn_rate{cgroup=/workload}":        2,
+		"psi_trig_warn_last{cgroup=/workload}":        75.0,
+		"psi_trig_warn_since_first{cgroup=/workload}": 75.0,
+	}
+	for column, value := range expected {
+		if v[column] != value {
+			t.Errorf("Expected %v for '%s', got %v", value, column, v[column])
+		}
+	}
+}
@@ -2,25 +2,136 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
-	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Reader interface {
+	resolvePath(path string) string
 	getSumAllIntValues(filename string, key string) ([]int64, error)
 	getTextValue(filename string, key string) (string, error)
 	getFloatValue(filename string, key string) (float64, error)
 	getIntValue(filename string, key string) (int64, error)
 	getIntWhole(filename string) (int64, error)
+	getTextWhole(filename string) (string, error)
 	getFloatKeyValuePairs(filename string) (result map[string]float64, err error)
+	readTime(filename string) time.Time
 }
 
+// fileSource provides raw file contents, e.g. from a recorded archive instead of the file system
+type fileSource interface {
+	readFile(filename string) ([]byte, error)
+}
+
+// FileReader reads procfs and sysfs files, which may be mounted to custom roots,
+// e.g. host's '/proc' bind-mounted to a container, or a fixture tree in tests
 type FileReader struct {
+	procRoot string
+	sysRoot  string
+	source   fileSource
+	snapshot *Snapshot
+}
+
+const (
+	defaultProcRoot = "/proc"
+	defaultSysRoot  = "/sys"
+)
+
+func replaceRoot(path string, defaultRoot string, root string) (string, bool) {
+	underRoot := path == defaultRoot || strings.HasPrefix(path, defaultRoot+"/")
+	if root == "" || root == defaultRoot {
+		return path, underRoot
+	}
+	if underRoot {
+		return filepath.Join(root, path[len(defaultRoot):]), true
+	}
+	return path, false
+}
+
+// resolvePath converts standard '/proc/...' and '/sys/...' paths to the actual ones
+func (o FileReader) resolvePath(path string) string {
+	if result, ok := replaceRoot(path, defaultProcRoot, o.procRoot); ok {
+		return result
+	}
+	result, _ := replaceRoot(path, defaultSysRoot, o.sysRoot)
+	return result
+}
+
+func (o FileReader) open(filename string) (io.Reader, error) {
+	content, err := o.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+func (o FileReader) readFile(filename string) ([]byte, error) {
+	if o.snapshot != nil {
+		file, err := o.snapshot.get(filename, o.readSourceFile)
+		if err != nil {
+			return nil, err
+		}
+		return file.content, nil
+	}
+	return o.readSourceFile(filename)
+}
+
+func (o FileReader) readSourceFile(filename string) ([]byte, error) {
+	if o.source != nil {
+		return o.source.readFile(filename)
+	}
+	return ioutil.ReadFile(o.resolvePath(filename))
+}
+
+// readTime returns the time the file was read at in the current snapshot, e.g. the frame time in a replay,
+// the current time without the snapshot
+func (o FileReader) readTime(filename string) time.Time {
+	if o.snapshot != nil {
+		if readTime, ok := o.snapshot.fileTime(filename); ok {
+			return readTime
+		}
+	}
+	return time.Now()
+}
+
+// parseKeys splits 'key value...' lines, only the first line with the key is taken into account
+func parseKeys(content []byte) (map[string][]string, error) {
+	result := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		data := strings.Fields(scanner.Text())
+		if len(data) < 2 {
+			continue
+		}
+		key := trimLastSemicolon(data[0])
+		if _, ok := result[key]; !ok {
+			result[key] = data[1:]
+		}
+	}
+	return result, scanner.Err()
+}
+
+func (o FileReader) getKeys(filename string) (map[string][]string, error) {
+	if o.snapshot != nil {
+		file, err := o.snapshot.get(filename, o.readSourceFile)
+		if err != nil {
+			return nil, err
+		}
+		return file.keys, file.err
+	}
+	content, err := o.readSourceFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeys(content)
 }
 
 func trimLastSemicolon(text string) string {
@@ -32,11 +143,10 @@ func trimLastSemicolon(text string) string {
 
 func (o FileReader) getFloatKeyValuePairs(filename string) (result map[string]float64, err error) {
 	result = make(map[string]float64)
-	file, err := os.Open(filename)
+	file, err := o.open(filename)
 	if err != nil {
 		return
 	}
-	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
@@ -55,11 +165,10 @@ func (o FileReader) getFloatKeyValuePairs(filename string) (result map[string]fl
 
 func (o FileReader) getSumAllIntValues(filename string, key string) ([]int64, error) {
 	var result []int64
-	file, err := os.Open(filename)
+	file, err := o.open(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
@@ -78,30 +187,17 @@ func (o FileReader) getSumAllIntValues(filename string, key string) ([]int64, er
 }
 
 func (o FileReader) getTextValue(filename string, key string) (string, error) {
-	var result string
-	file, err := os.Open(filename)
+	keys, err := o.getKeys(filename)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		data := strings.Fields(scanner.Text())
-		if trimLastSemicolon(data[0]) == key {
-			result = data[1]
-			break
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
-	if len(result) == 0 {
+	values, ok := keys[key]
+	if !ok {
 		err = fmt.Errorf("Key '%s' was not found in '%s'", key, filename)
 		return "", err
 	}
-	return result, nil
+	return values[0], nil
 }
 
 func (o FileReader) getFloatValue(filename string, key string) (float64, error) {
@@ -122,8 +218,16 @@ func (o FileReader) getIntValue(filename string, key string) (int64, error) {
 	return result, err
 }
 
+func (o FileReader) getTextWhole(filename string) (string, error) {
+	text, err := o.readFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(text)), nil
+}
+
 func (o FileReader) getIntWhole(filename string) (int64, error) {
-	text, err := ioutil.ReadFile(filename)
+	text, err := o.readFile(filename)
 	if err != nil {
 		return 0, err
 	}
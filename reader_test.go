@@ -1,51 +1,12 @@
 package main
 
 import "reflect"
-import "strings"
 import "testing"
 
-type FileReaderStub struct {
-}
-
-func (r FileReaderStub) generateFakePath(realPath string) string {
-	const fakePathPrefix string = "./test_samples/"
-	pathElements := strings.Split(realPath, "/")
-	filename := pathElements[len(pathElements)-1]
-	return fakePathPrefix + filename + ".txt"
-}
-
-func (r FileReaderStub) getSumAllIntValues(filename string, key string) ([]int64, error) {
-	o := FileReader{}
-	return o.getSumAllIntValues(r.generateFakePath(filename), key)
-}
-
-func (r FileReaderStub) getTextValue(filename string, key string) (string, error) {
-	o := FileReader{}
-	return o.getTextValue(r.generateFakePath(filename), key)
-}
-
-func (r FileReaderStub) getFloatValue(filename string, key string) (float64, error) {
-	o := FileReader{}
-	return o.getFloatValue(r.generateFakePath(filename), key)
-}
-
-func (r FileReaderStub) getIntValue(filename string, key string) (int64, error) {
-	o := FileReader{}
-	return o.getIntValue(r.generateFakePath(filename), key)
-}
-
-func (r FileReaderStub) getIntWhole(filename string) (int64, error) {
-	o := FileReader{}
-	return o.getIntWhole(r.generateFakePath(filename))
-}
-
-func (r FileReaderStub) getFloatKeyValuePairs(filename string) (result map[string]float64, err error) {
-	o := FileReader{}
-	return o.getFloatKeyValuePairs(r.generateFakePath(filename))
-}
+const testProcRoot = "./test_samples/proc"
 
 func TestGetKeyValuePairs(t *testing.T) {
-	r := FileReaderStub{}
+	r := FileReader{procRoot: testProcRoot}
 	fetchedData, err := r.getFloatKeyValuePairs(meminfoFile)
 	if err != nil {
 		t.Fatal(err)
@@ -70,7 +31,7 @@ func TestGetKeyValuePairs(t *testing.T) {
 }
 
 func TestGetTextValue(t *testing.T) {
-	r := FileReaderStub{}
+	r := FileReader{procRoot: testProcRoot}
 	fetchedData, err := r.getTextValue(psiMemoryFile, "some")
 	if err != nil {
 		t.Fatal(err)
@@ -82,7 +43,7 @@ func TestGetTextValue(t *testing.T) {
 }
 
 func TestGetAllIntValues(t *testing.T) {
-	r := FileReaderStub{}
+	r := FileReader{procRoot: testProcRoot}
 	fetchedData, err := r.getSumAllIntValues(zoneFile, "low")
 	if err != nil {
 		t.Fatal(err)
@@ -94,3 +55,24 @@ func TestGetAllIntValues(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestResolvePath(t *testing.T) {
+	r := FileReader{procRoot: "/host/proc", sysRoot: "/host/sys/"}
+	paths := map[string]string{
+		meminfoFile:          "/host/proc/meminfo",
+		eventControlPath:     "/host/sys/fs/cgroup/memory/cgroup.event_control",
+		"/processes/meminfo": "/processes/meminfo",
+		"/proc":              "/host/proc",
+		"./local/file":       "./local/file",
+	}
+	for path, expected := range paths {
+		if resolved := r.resolvePath(path); resolved != expected {
+			t.Errorf("Path '%s' resolved incorrectly, expected '%s', got '%s'", path, expected, resolved)
+		}
+	}
+
+	r = FileReader{}
+	if resolved := r.resolvePath(meminfoFile); resolved != meminfoFile {
+		t.Errorf("Default roots must not change the path, got '%s'", resolved)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Archive of a recorded run is a JSON Lines file, one frame per main loop iteration.
+// Every frame contains raw contents of all the files observers have read during
+// this iteration and the values of all the tracked metrics, so the metrics that
+// can't be calculated from the files (e.g. 'alloctd', 'cgroups' or 'psi_trig')
+// are also available in a replay.
+type recordFrame struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Event     string            `json:"event"`
+	Files     map[string]string `json:"files"`
+	Values    []recordedValue   `json:"values"`
+}
+
+type recordedValue struct {
+	Name   string      `json:"name"`
+	Labels Labels      `json:"labels,omitempty"`
+	Value  interface{} `json:"value"`
+}
+
+// Recorder is a file source, which reads real files and saves their contents
+// to the archive, and a sink, which closes a frame on every sample.
+type Recorder struct {
+	mtx     sync.Mutex
+	reader  FileReader
+	out     io.WriteCloser
+	encoder *json.Encoder
+	files   map[string]string
+}
+
+// newRecorder creates a recorder, which reads the files with the given reader,
+// files are saved with their standard paths, so the archive doesn't depend on procfs and sysfs roots
+func newRecorder(filename string, r FileReader) (*Recorder, error) {
+	out, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{reader: r, out: out, encoder: json.NewEncoder(out), files: make(map[string]string)}, nil
+}
+
+func (o *Recorder) readFile(filename string) ([]byte, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	// a replay gets only one version of the file per frame,
+	// so the same content is used for all reads during one iteration
+	if content, ok := o.files[filename]; ok {
+		return []byte(content), nil
+	}
+	content, err := o.reader.readFile(filename)
+	if err == nil {
+		o.files[filename] = string(content)
+	}
+	return content, err
+}
+
+func (o *Recorder) Write(s *Sample) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	frame := recordFrame{Timestamp: s.Timestamp, Event: s.Event, Files: o.files}
+	for _, v := range s.Values {
+		value := v.Value
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = nil
+		}
+		frame.Values = append(frame.Values, recordedValue{v.Metric.Name, v.Labels, value})
+	}
+	o.files = make(map[string]string)
+	return o.encoder.Encode(&frame)
+}
+
+func (o *Recorder) Close() error {
+	return o.out.Close()
+}
+
+// Replayer is a file source, which serves file contents from the recorded archive.
+// It also provides a virtual clock, which shows the time of the actual frame.
+type Replayer struct {
+	in      io.ReadCloser
+	decoder *json.Decoder
+	frame   recordFrame
+}
+
+func newReplayer(filename string) (*Replayer, error) {
+	in, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	decoder.UseNumber()
+	return &Replayer{in: in, decoder: decoder}, nil
+}
+
+// next moves to the next frame, it returns false at the end of the archive
+func (o *Replayer) next() (bool, error) {
+	var frame recordFrame
+	if err := o.decoder.Decode(&frame); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("Failed to read the recorded frame: %v", err)
+	}
+	o.frame = frame
+	return true, nil
+}
+
+func (o *Replayer) readFile(filename string) ([]byte, error) {
+	content, ok := o.frame.Files[filename]
+	if !ok {
+		return nil, &os.PathError{Op: "replay", Path: filename, Err: os.ErrNotExist}
+	}
+	return []byte(content), nil
+}
+
+func (o *Replayer) now() time.Time {
+	return o.frame.Timestamp
+}
+
+func (o *Replayer) event() string {
+	return o.frame.Event
+}
+
+// restoreValues puts recorded metrics values into the tracker,
+// passive observers overwrite their own metrics after that
+func (o *Replayer) restoreValues(t *Tracker) {
+	for _, v := range o.frame.Values {
+		var value interface{} = math.NaN()
+		if number, ok := v.Value.(json.Number); ok {
+			if i, err := number.Int64(); err == nil {
+				value = i
+			} else if f, err := number.Float64(); err == nil {
+				value = f
+			}
+		}
+		t.trackLabeled(v.Name, v.Labels, value)
+	}
+}
+
+func (o *Replayer) Close() error {
+	return o.in.Close()
+}
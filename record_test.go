@@ -0,0 +1,65 @@
+package main
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memory-pressure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "record.jsonl")
+
+	recorder, err := newRecorder(archive, FileReader{procRoot: testProcRoot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Tracker{}
+	tr.trackOne(allocatedKey, 256)
+	reader := FileReader{source: recorder}
+	recordedValue, err := reader.getIntValue(vmstatPath, "pgmajfault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = recorder.Write(tr.sample("timer")); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Close()
+
+	replayer, err := newReplayer(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+	if ok, err := replayer.next(); !ok {
+		t.Fatal("Recorded frame is missing: ", err)
+	}
+	if replayer.event() != "timer" {
+		t.Errorf("Wrong replayed event '%s'", replayer.event())
+	}
+
+	reader = FileReader{source: replayer}
+	replayedValue, err := reader.getIntValue(vmstatPath, "pgmajfault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayedValue != recordedValue {
+		t.Errorf("Wrong replayed value, expected %d, got %d", recordedValue, replayedValue)
+	}
+	if _, err := reader.getIntWhole(swapinessPath); err == nil {
+		t.Error("File, which wasn't recorded, must not be available in the replay")
+	}
+
+	replayTracker := Tracker{}
+	replayer.restoreValues(&replayTracker)
+	if v := replayTracker.snapshot()[0].Value; v != int64(256) {
+		t.Errorf("Wrong replayed '%s' value %#v", allocatedKey, v)
+	}
+
+	if ok, _ := replayer.next(); ok {
+		t.Error("Only one frame was recorded")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const alertKey = "alert"
+
+type AlertState int
+
+const (
+	AlertInactive AlertState = iota
+	// the condition holds, but not for the required duration yet
+	AlertPending
+	AlertFiring
+)
+
+func (s AlertState) String() string {
+	switch s {
+	case AlertPending:
+		return "pending"
+	case AlertFiring:
+		return "firing"
+	default:
+		return "inactive"
+	}
+}
+
+type condition struct {
+	op        string
+	threshold float64
+}
+
+func (c condition) holds(value float64) bool {
+	switch c.op {
+	case ">":
+		return value > c.threshold
+	case ">=":
+		return value >= c.threshold
+	case "<":
+		return value < c.threshold
+	case "<=":
+		return value <= c.threshold
+	}
+	return false
+}
+
+func parseCondition(op, threshold string) (condition, error) {
+	switch op {
+	case ">", ">=", "<", "<=":
+	default:
+		return condition{}, fmt.Errorf("Unknown comparison operator '%s'", op)
+	}
+	value, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return condition{}, err
+	}
+	return condition{op, value}, nil
+}
+
+// Rule is a threshold alert on a tracked series, e.g. 'psi_full > 10 for 5s'.
+// The alert fires when the condition holds for the duration, and resolves when the clear
+// condition holds, or, if there is no clear condition, when the condition doesn't hold anymore.
+type Rule struct {
+	Name   string
+	Series string
+	When   condition
+	For    time.Duration
+	Clear  *condition
+	state  AlertState
+	since  time.Time
+}
+
+// parseRule parses '[name:]series op value [for duration] [clear op value]'
+func parseRule(spec string) (*Rule, error) {
+	rule := &Rule{}
+	expression := spec
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		rule.Name = strings.TrimSpace(spec[:i])
+		expression = spec[i+1:]
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("Rule '%s' is expected as '[name:]series op value [for duration] [clear op value]'", spec)
+	}
+	rule.Series = fields[0]
+	var err error
+	if rule.When, err = parseCondition(fields[1], fields[2]); err != nil {
+		return nil, fmt.Errorf("Rule '%s': %v", spec, err)
+	}
+	rest := fields[3:]
+	if len(rest) >= 2 && rest[0] == "for" {
+		if rule.For, err = time.ParseDuration(rest[1]); err != nil {
+			return nil, fmt.Errorf("Rule '%s': %v", spec, err)
+		}
+		rest = rest[2:]
+	}
+	if len(rest) == 3 && rest[0] == "clear" {
+		clear, err := parseCondition(rest[1], rest[2])
+		if err != nil {
+			return nil, fmt.Errorf("Rule '%s': %v", spec, err)
+		}
+		rule.Clear = &clear
+		rest = rest[3:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("Rule '%s' has unexpected '%s'", spec, strings.Join(rest, " "))
+	}
+
+	if rule.Name == "" {
+		rule.Name = strings.Join(fields, " ")
+	}
+	return rule, nil
+}
+
+// value returns the actual value of the rule's series, NaN if it isn't tracked
+func (r *Rule) value(values map[string]float64) float64 {
+	if value, ok := values[r.Series]; ok {
+		return value
+	}
+	return math.NaN()
+}
+
+// update moves the alert to the next state, it returns true on a transition
+func (r *Rule) update(value float64, now time.Time) bool {
+	previous := r.state
+	holds := r.When.holds(value) // NaN never holds
+	switch r.state {
+	case AlertInactive:
+		if holds {
+			r.state = AlertPending
+			r.since = now
+		}
+	case AlertPending:
+		if !holds {
+			r.state = AlertInactive
+		}
+	case AlertFiring:
+		if r.Clear != nil {
+			if r.Clear.holds(value) {
+				r.state = AlertInactive
+			}
+		} else if !holds {
+			r.state = AlertInactive
+		}
+	}
+	if r.state == AlertPending && now.Sub(r.since) >= r.For {
+		r.state = AlertFiring
+	}
+	return r.state != previous
+}
+
+// ruleList is a repeatable '-rule' command-line option
+type ruleList []*Rule
+
+func (l *ruleList) String() string {
+	var names []string
+	for _, r := range *l {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *ruleList) Set(value string) error {
+	rule, err := parseRule(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, rule)
+	return nil
+}
+
+// seriesValues returns the actual values of all the numeric series by their names
+func seriesValues(t *Tracker) map[string]float64 {
+	values := make(map[string]float64)
+	for _, v := range t.snapshot() {
+		if value, ok := numericValue(v.Value); ok {
+			values[v.Column] = value
+		}
+	}
+	return values
+}
+
+// RuleEngine evaluates threshold rules on every update and tracks the alerts states
+type RuleEngine struct {
+	tracker *Tracker
+	rules   ruleList
+}
+
+func (o *RuleEngine) SetFlags() {
+	flag.Var(&o.rules, "rule", "threshold alert as '[name:]series op value [for duration] [clear op value]', e.g. 'psi_full > 10 for 5s', can be repeated")
+}
+
+func (o *RuleEngine) Initialize(t *Tracker) {
+	o.tracker = t
+	if len(o.rules) == 0 {
+		return
+	}
+	o.tracker.register(Metric{Name: alertKey, Type: Gauge, Help: "Alert state of the rule (0 - inactive, 1 - pending, 2 - firing)", Labels: []string{"rule"}})
+	for _, r := range o.rules {
+		o.tracker.trackLabeled(alertKey, Labels{{"rule", r.Name}}, int(r.state))
+	}
+}
+
+func (o *RuleEngine) Process() {
+	if len(o.rules) == 0 {
+		return
+	}
+	values := seriesValues(o.tracker)
+	now := o.tracker.now()
+	for _, r := range o.rules {
+		value := r.value(values)
+		previous := r.state
+		if r.update(value, now) {
+			log.Printf("Alert '%s' has changed from '%s' to '%s': %s=%.2f", r.Name, previous, r.state, r.Series, value)
+		}
+		o.tracker.trackLabeled(alertKey, Labels{{"rule", r.Name}}, int(r.state))
+	}
+}
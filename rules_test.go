@@ -0,0 +1,76 @@
+package main
+
+import "math"
+import "testing"
+import "time"
+
+func TestParseRule(t *testing.T) {
+	rule, err := parseRule("swap:swp_flts_mult > 3 for 30s clear < 1.5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rule.Name != "swap" || rule.Series != "swp_flts_mult" || rule.When != (condition{">", 3}) || rule.For != 30*time.Second {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+	if rule.Clear == nil || *rule.Clear != (condition{"<", 1.5}) {
+		t.Errorf("Expected clear condition '< 1.5', got %v", rule.Clear)
+	}
+
+	rule, err = parseRule("mem_pcnt  >=  90")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rule.Name != "mem_pcnt >= 90" || rule.For != 0 || rule.Clear != nil {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+
+	for _, spec := range []string{"psi_full", "psi_full = 10", "psi_full > ten", "psi_full > 10 for", "psi_full > 10 during 5s"} {
+		if _, err := parseRule(spec); err == nil {
+			t.Errorf("Expected an error for '%s'", spec)
+		}
+	}
+}
+
+func TestRuleStates(t *testing.T) {
+	rule, _ := parseRule("swp_flts_mult > 3 for 10s clear < 1.5")
+	start := time.Unix(1568822281, 0)
+	steps := []struct {
+		value    float64
+		expected AlertState
+	}{
+		{1, AlertInactive},
+		{4, AlertPending},
+		{2, AlertInactive},
+		{5, AlertPending},
+		{5, AlertPending},
+		{6, AlertFiring},
+		{2, AlertFiring},
+		{math.NaN(), AlertFiring},
+		{1, AlertInactive},
+	}
+	for i, step := range steps {
+		rule.update(step.value, start.Add(time.Duration(i*5)*time.Second))
+		if rule.state != step.expected {
+			t.Errorf("Step %d: expected '%s' state, got '%s'", i, step.expected, rule.state)
+		}
+	}
+}
+
+func TestRuleEngine(t *testing.T) {
+	tr := Tracker{}
+	o := RuleEngine{}
+	o.rules.Set("full:psi_full > 10")
+	o.Initialize(&tr)
+
+	tr.trackOne(psiFullKey, 12.5)
+	o.Process()
+	if value := snapshotValues(&tr)["alert{rule=full}"]; value != float64(AlertFiring) {
+		t.Errorf("Expected firing alert, got %v", value)
+	}
+
+	tr.trackOne(psiFullKey, 3.0)
+	o.Process()
+	if value := snapshotValues(&tr)["alert{rule=full}"]; value != float64(AlertInactive) {
+		t.Errorf("Expected inactive alert, got %v", value)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+	formatHTTP  = "http"
+)
+
+// Sample is a state of all the tracked metrics at one iteration of the main loop,
+// ReadTime is the time when the files for this iteration were read, zero if there were no reads
+type Sample struct {
+	Timestamp time.Time
+	ReadTime  time.Time
+	Event     string
+	Values    []MetricValue
+}
+
+type Sink interface {
+	Write(s *Sample) error
+	Close() error
+}
+
+// sinkList is a repeatable '-sink' command-line option
+type sinkList []string
+
+func (l *sinkList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *sinkList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// newSink creates a sink from 'type[:target]' specification, e.g. 'csv:run.csv' or 'http:127.0.0.1:9101'
+func newSink(spec string) (Sink, error) {
+	kind := spec
+	target := ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		kind = spec[:i]
+		target = spec[i+1:]
+	}
+
+	switch kind {
+	case formatHTTP:
+		if target == "" {
+			return nil, fmt.Errorf("Listen address is required for '%s' sink", spec)
+		}
+		e := &PrometheusExporter{}
+		e.start(target)
+		return e, nil
+	case formatTable, formatJSONL, formatCSV:
+	default:
+		return nil, fmt.Errorf("Unknown sink type '%s'", kind)
+	}
+
+	out, err := openSinkOutput(target)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case formatJSONL:
+		return &JSONLSink{out: out}, nil
+	case formatCSV:
+		return newCSVSink(out), nil
+	default:
+		return &TableSink{out: out}, nil
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func openSinkOutput(filename string) (io.WriteCloser, error) {
+	if filename == "" || filename == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(filename)
+}
+
+// columnSet tracks the header of column-based outputs.
+// Metrics are never removed from the tracker, so any difference between
+// the printed header and the sample means that some metric appeared
+// after the header had been printed (e.g. an observer failed its first read).
+type columnSet struct {
+	fields []string
+}
+
+func (c *columnSet) update(s *Sample) bool {
+	if c.fields != nil && len(c.fields) == len(s.Values) {
+		return false
+	}
+	c.fields = make([]string, 0, len(s.Values))
+	for _, v := range s.Values {
+		c.fields = append(c.fields, v.Column)
+	}
+	sort.Strings(c.fields)
+	return true
+}
+
+func sampleValues(s *Sample) map[string]interface{} {
+	result := make(map[string]interface{}, len(s.Values))
+	for _, v := range s.Values {
+		result[v.Column] = v.Value
+	}
+	return result
+}
+
+type TableSink struct {
+	out     io.WriteCloser
+	columns columnSet
+}
+
+func (o *TableSink) Write(s *Sample) error {
+	if o.columns.update(s) {
+		for _, j := range o.columns.fields {
+			fmt.Fprintf(o.out, "%*s, ", len(j), j)
+		}
+		fmt.Fprintln(o.out, "")
+	}
+
+	values := sampleValues(s)
+	for _, k := range o.columns.fields {
+		switch values[k].(type) {
+		default:
+			fmt.Fprintf(o.out, "%*v, ", len(k), values[k])
+		case float64:
+			fmt.Fprintf(o.out, "%*.2f, ", len(k), values[k].(float64))
+		}
+	}
+	_, err := fmt.Fprintln(o.out, "")
+	return err
+}
+
+func (o *TableSink) Close() error {
+	return o.out.Close()
+}
+
+type CSVSink struct {
+	out     io.WriteCloser
+	writer  *csv.Writer
+	columns columnSet
+}
+
+func newCSVSink(out io.WriteCloser) *CSVSink {
+	return &CSVSink{out: out, writer: csv.NewWriter(out)}
+}
+
+func csvValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (o *CSVSink) Write(s *Sample) error {
+	printed := o.columns.fields
+	if o.columns.update(s) {
+		if err := o.writeHeader(printed); err != nil {
+			return err
+		}
+	}
+
+	values := sampleValues(s)
+	record := make([]string, len(o.columns.fields))
+	for n, k := range o.columns.fields {
+		record[n] = csvValue(values[k])
+	}
+	if err := o.writer.Write(record); err != nil {
+		return err
+	}
+	// flush every line, so the file can be analyzed while the test is still running
+	o.writer.Flush()
+	return o.writer.Error()
+}
+
+// writeHeader writes the header of the actual columns. When some metric appears after
+// the header has been printed, a regular file is rewritten with the new header and
+// the new columns left empty in the former rows, so it stays a valid CSV table.
+// Other outputs (e.g. stdout or a pipe) can't be rewritten, they get the new header inline.
+func (o *CSVSink) writeHeader(printed []string) error {
+	if file, ok := o.out.(*os.File); ok && printed != nil {
+		info, err := file.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			return o.rewrite(file, printed)
+		}
+	}
+	return o.writer.Write(o.columns.fields)
+}
+
+func (o *CSVSink) rewrite(file *os.File, printed []string) error {
+	o.writer.Flush()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	position := make(map[string]int, len(printed))
+	for n, k := range printed {
+		position[k] = n
+	}
+	if err := o.writer.Write(o.columns.fields); err != nil {
+		return err
+	}
+	// the first row is the former header
+	for _, row := range rows[1:] {
+		record := make([]string, len(o.columns.fields))
+		for n, k := range o.columns.fields {
+			if i, ok := position[k]; ok && i < len(row) {
+				record[n] = row[i]
+			}
+		}
+		if err := o.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *CSVSink) Close() error {
+	o.writer.Flush()
+	return o.out.Close()
+}
+
+type JSONLSink struct {
+	out io.WriteCloser
+}
+
+func (o *JSONLSink) Write(s *Sample) error {
+	const timestampKey = "timestamp"
+	const readTimestampKey = "read_timestamp"
+	const eventKey = "event"
+
+	record := make(map[string]interface{}, len(s.Values)+3)
+	for _, v := range s.Values {
+		value := v.Value
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			// JSON has no representation for NaN, missing values are written as null
+			value = nil
+		}
+		record[v.Column] = value
+	}
+	record[timestampKey] = s.Timestamp.Format(time.RFC3339Nano)
+	if !s.ReadTime.IsZero() {
+		record[readTimestampKey] = s.ReadTime.Format(time.RFC3339Nano)
+	}
+	record[eventKey] = s.Event
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.out, string(line))
+	return err
+}
+
+func (o *JSONLSink) Close() error {
+	return o.out.Close()
+}
+
+func writeToSinks(sinks []Sink, s *Sample) {
+	for _, sink := range sinks {
+		if err := sink.Write(s); err != nil {
+			log.Print("Failed to write the sample: ", err)
+		}
+	}
+}
+
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Print("Failed to close the sink: ", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import "bytes"
+import "encoding/json"
+import "math"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+func TestCSVSinkMissingValues(t *testing.T) {
+	var buf bytes.Buffer
+	tr := Tracker{}
+	tr.trackOne("mem_pcnt", 12.5)
+	tr.trackOne("swp_tend", math.NaN())
+	tr.trackOne("alloctd", 128)
+	sink := newCSVSink(nopCloser{&buf})
+	if err := sink.Write(tr.sample("timer")); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "alloctd,mem_pcnt,swp_tend\n128,12.5,\n"
+	if buf.String() != expected {
+		t.Errorf("Wrong CSV output, expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCSVSinkLateMetric(t *testing.T) {
+	var buf bytes.Buffer
+	tr := Tracker{}
+	tr.trackOne("mem_pcnt", 12.5)
+	sink := newCSVSink(nopCloser{&buf})
+	sink.Write(tr.sample("timer"))
+	tr.trackOne("psi_trig", 1) // appeared after the header, must not be dropped
+	sink.Write(tr.sample("notify"))
+
+	const expected = "mem_pcnt\n12.5\nmem_pcnt,psi_trig\n12.5,1\n"
+	if buf.String() != expected {
+		t.Errorf("Wrong CSV output, expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCSVSinkRewritesFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "run.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := Tracker{}
+	tr.trackOne("mem_pcnt", 12.5)
+	tr.trackOne("swp_tend", 0.5)
+	sink := newCSVSink(file)
+	sink.Write(tr.sample("timer"))
+	sink.Write(tr.sample("timer"))
+	tr.trackOne("psi_trig", 1) // appeared after the header, the file gets the new one
+	sink.Write(tr.sample("notify"))
+	sink.Close()
+
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "mem_pcnt,psi_trig,swp_tend\n12.5,,0.5\n12.5,,0.5\n12.5,1,0.5\n"
+	if string(content) != expected {
+		t.Errorf("Wrong CSV file, expected %q, got %q", expected, content)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	if _, err := newSink("xml:file.xml"); err == nil {
+		t.Error("Unknown sink type must be rejected")
+	}
+	if _, err := newSink("http"); err == nil {
+		t.Error("HTTP sink without listen address must be rejected")
+	}
+	sink, err := newSink("jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sink.(*JSONLSink); !ok {
+		t.Errorf("Wrong sink type %T", sink)
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := JSONLSink{out: nopCloser{&buf}}
+	timestamp := time.Date(2019, 9, 18, 16, 58, 1, 0, time.UTC)
+
+	tr := Tracker{}
+	tr.trackOne(memPercentKey, 12.5)
+	tr.trackOne(tendencyKey, math.NaN())
+	tr.trackOne(allocatedKey, 128)
+	s := tr.sample("timer")
+	s.Timestamp = timestamp
+	sink.Write(s)
+	s = tr.sample("notify")
+	s.Timestamp = timestamp.Add(time.Second)
+	sink.Write(s)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one JSON object per line, got:\n%s", buf.String())
+	}
+	var first, second map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Invalid JSON line '%s': %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Invalid JSON line '%s': %v", lines[1], err)
+	}
+
+	expected := map[string]interface{}{
+		memPercentKey: 12.5,
+		allocatedKey:  128.0,
+		"timestamp":   "2019-09-18T16:58:01Z",
+		"event":       "timer",
+	}
+	for k, v := range expected {
+		if first[k] != v {
+			t.Errorf("Expected %v for '%s', got %v", v, k, first[k])
+		}
+	}
+	if value, ok := first[tendencyKey]; !ok || value != nil {
+		t.Errorf("NaN must be written as null, got %v", value)
+	}
+	if second["event"] != "notify" || second["timestamp"] != "2019-09-18T16:58:02Z" {
+		t.Errorf("Wrong second JSON line: %s", lines[1])
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot keeps contents of all the files read during one iteration of the main loop,
+// so every file is read only once per sample, and all the metrics in a row are coherent
+type Snapshot struct {
+	mtx      sync.Mutex
+	clock    func() time.Time
+	files    map[string]*snapshotFile
+	readTime time.Time
+}
+
+type snapshotFile struct {
+	content  []byte
+	readTime time.Time
+	keys     map[string][]string
+	err      error
+}
+
+func newSnapshot(clock func() time.Time) *Snapshot {
+	return &Snapshot{clock: clock, files: make(map[string]*snapshotFile)}
+}
+
+// reset drops all the files, they're read again on the next request
+func (o *Snapshot) reset() {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.files = make(map[string]*snapshotFile)
+	o.readTime = time.Time{}
+}
+
+func (o *Snapshot) get(filename string, read func(filename string) ([]byte, error)) (*snapshotFile, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if file, ok := o.files[filename]; ok {
+		return file, nil
+	}
+	content, err := read(filename)
+	if err != nil {
+		// failed reads aren't cached, the file may appear later (e.g. cgroup is created)
+		return nil, err
+	}
+	file := &snapshotFile{content: content, readTime: o.clock()}
+	// files are parsed once, all the following key lookups use the parsed data
+	file.keys, file.err = parseKeys(content)
+	if o.readTime.IsZero() {
+		o.readTime = file.readTime
+	}
+	o.files[filename] = file
+	return file, nil
+}
+
+// fileTime returns the time the file was read at in this snapshot
+func (o *Snapshot) fileTime(filename string) (time.Time, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if file, ok := o.files[filename]; ok {
+		return file.readTime, true
+	}
+	return time.Time{}, false
+}
+
+// time returns the time of the first file read in this snapshot, zero if nothing was read
+func (o *Snapshot) time() time.Time {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return o.readTime
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+import "time"
+
+type countingSource struct {
+	reader FileReader
+	reads  map[string]int
+}
+
+func (o *countingSource) readFile(filename string) ([]byte, error) {
+	o.reads[filename]++
+	return o.reader.readFile(filename)
+}
+
+func TestSnapshotReadsOnce(t *testing.T) {
+	source := &countingSource{FileReader{procRoot: testProcRoot}, make(map[string]int)}
+	readTime := time.Unix(1568822281, 0)
+	snapshot := newSnapshot(func() time.Time { return readTime })
+	r := FileReader{source: source, snapshot: snapshot}
+
+	o := SwapObserver{reader: r, hertz: 100}
+	if _, _, err := o.getValuesForFaults(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := o.getValuesForTendency(); err != nil {
+		t.Fatal(err)
+	}
+	if source.reads[vmstatPath] != 1 {
+		t.Errorf("'%s' must be read once per snapshot, was read %d times", vmstatPath, source.reads[vmstatPath])
+	}
+	if !snapshot.time().Equal(readTime) {
+		t.Errorf("Wrong snapshot read time %v", snapshot.time())
+	}
+
+	snapshot.reset()
+	if !snapshot.time().IsZero() {
+		t.Error("Read time must be cleared after reset")
+	}
+	if _, err := r.getIntValue(vmstatPath, "pgmajfault"); err != nil {
+		t.Fatal(err)
+	}
+	if source.reads[vmstatPath] != 2 {
+		t.Errorf("'%s' must be read again after reset", vmstatPath)
+	}
+}
@@ -15,6 +15,13 @@ const vmstatPath = "/proc/vmstat"
 const meminfoPath = "/proc/meminfo"
 const swapinessPath = "/proc/sys/vm/swappiness"
 
+const (
+	faultsSecKey        = "swp_flts_sec"
+	faultsSecFilterKey  = "swp_flts_sec_f"
+	faultsMultiplierKey = "swp_flts_mult"
+	tendencyKey         = "swp_tend"
+)
+
 type SwapObserver struct {
 	tracker                *Tracker
 	reader                 Reader
@@ -46,6 +53,15 @@ func (o *SwapObserver) Initialize(t *Tracker, r Reader) {
 	log.Printf("System timer frequency is %d Hz", o.hertz)
 	o.oldValues.lastUserTime = 0
 
+	metrics := []Metric{
+		{Name: faultsSecKey, Type: Gauge, Unit: unitFaultsPerSecond, Help: "Major page faults per second of CPU time", Export: "major_faults_per_second"},
+		{Name: faultsSecFilterKey, Type: Gauge, Unit: unitFaultsPerSecond, Help: "Major page faults per second with EWMA low-pass filter", Export: "major_faults_per_second_filtered"},
+		{Name: faultsMultiplierKey, Type: Gauge, Unit: unitNone, Help: "Current to average major page faults per second ratio", Export: "major_faults_ratio"},
+	}
+	if o.showTendency {
+		metrics = append(metrics, Metric{Name: tendencyKey, Type: Gauge, Unit: unitNone, Help: "'Swap tendency' value, see README for the details", Export: "swap_tendency"})
+	}
+	o.tracker.register(metrics...)
 	o.process()
 }
 
@@ -135,12 +151,6 @@ func (o *SwapObserver) calculateSwapFaults(newMajorPageFaults int64, newUserExec
 }
 
 func (o *SwapObserver) analyze() (map[string]interface{}, error) {
-	const pgmajfaultKey string = "swp_flts"
-	const faultsSecKey string = "swp_flts_sec"
-	const tendencyKey string = "swp_tend"
-	const faultsSecFilterKey string = "swp_flts_sec_f"
-	const faultsMultiplierKey string = "swp_flts_mult"
-
 	newMajorPageFaults, newUserExecTime, err := o.getValuesForFaults()
 	if err != nil {
 		return nil, err
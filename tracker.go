@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"log"
 	"sort"
 	"sync"
 	"time"
@@ -9,64 +9,112 @@ import (
 
 type Tracker struct {
 	mtx       sync.Mutex
-	actual    map[string]interface{}
-	fields    []string
+	metrics   map[string]*trackedMetric
 	startTime int64
+	clock     func() time.Time
+}
+
+const timeKey = "time"
+
+// trackedMetric is a metric declaration together with the actual values of all its series.
+// Metrics, which no observer has declared, are tracked as untyped ones.
+type trackedMetric struct {
+	Metric
+	declared bool
+	series   map[string]trackedSeries
+}
+
+type trackedSeries struct {
+	labels Labels
+	value  interface{}
+}
+
+// now returns the actual time, or the virtual time when a recorded run is replayed
+func (t *Tracker) now() time.Time {
+	if t.clock != nil {
+		return t.clock()
+	}
+	return time.Now()
 }
 
 func (t *Tracker) prepare() {
-	t.startTime = time.Now().Unix()
+	t.startTime = t.now().Unix()
+	t.register(Metric{Name: timeKey, Type: Counter, Unit: unitSeconds, Help: "Time since the process start", Export: "elapsed_seconds"})
 	t.saveTime()
 }
 
-func (t *Tracker) track(update *map[string]interface{}) {
-	if t.actual == nil {
-		t.actual = make(map[string]interface{})
+// register declares metrics, which an observer is going to track
+func (t *Tracker) register(metrics ...Metric) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, metric := range metrics {
+		m := t.metric(metric.Name)
+		m.Metric = metric
+		m.declared = true
 	}
+}
+
+// metric returns the registry entry of a metric, an undeclared one is created on the first use
+func (t *Tracker) metric(name string) *trackedMetric {
+	if t.metrics == nil {
+		t.metrics = make(map[string]*trackedMetric)
+	}
+	m, ok := t.metrics[name]
+	if !ok {
+		m = &trackedMetric{Metric: Metric{Name: name, Type: Untyped}, series: make(map[string]trackedSeries)}
+		t.metrics[name] = m
+	}
+	return m
+}
+
+func (t *Tracker) track(update *map[string]interface{}) {
 	for k, v := range *update {
 		t.trackOne(k, v)
 	}
 }
 
 func (t *Tracker) trackOne(key string, value interface{}) {
+	t.trackLabeled(key, nil, value)
+}
+
+func (t *Tracker) trackLabeled(key string, labels Labels, value interface{}) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
-	if t.actual == nil {
-		t.actual = make(map[string]interface{})
+	m := t.metric(key)
+	if m.declared && !m.labelsMatch(labels) {
+		log.Printf("Labels %v don't match '%s' metric declaration, skipping", labels, key)
+		return
 	}
-
-	t.actual[key] = value
+	m.series[seriesName(key, labels)] = trackedSeries{labels, value}
 }
 
-func (t *Tracker) prepareAndPrintHeader() {
-	t.fields = make([]string, 0)
-	for k := range t.actual {
-		t.fields = append(t.fields, k)
-	}
-	sort.Strings(t.fields)
-	for _, j := range t.fields {
-		fmt.Printf("%*s, ", len(j), j)
-	}
-	fmt.Println("")
+func (t *Tracker) sample(event string) *Sample {
+	return &Sample{Timestamp: t.now(), Event: event, Values: t.snapshot()}
 }
 
-func (t *Tracker) printData() {
+// snapshot returns a copy of all actual values with their metadata, series of one metric go together
+func (t *Tracker) snapshot() []MetricValue {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
 
-	for n, k := range t.fields {
-		switch t.actual[k].(type) {
-		default:
-			fmt.Printf("%*v, ", len(t.fields[n]), t.actual[k])
-		case float64:
-			fmt.Printf("%*.2f, ", len(t.fields[n]), t.actual[k].(float64))
+	result := make([]MetricValue, 0, len(t.metrics))
+	for _, m := range t.metrics {
+		for column, s := range m.series {
+			result = append(result, MetricValue{column, m.Metric, s.labels, s.value})
 		}
 	}
-	fmt.Println("")
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Metric.Name != result[j].Metric.Name {
+			return result[i].Metric.Name < result[j].Metric.Name
+		}
+		return result[i].Column < result[j].Column
+	})
+	return result
 }
 
 func (t *Tracker) saveTime() {
-	actualTime := time.Now().Unix() - t.startTime
-	t.trackOne("time", actualTime)
+	actualTime := t.now().Unix() - t.startTime
+	t.trackOne(timeKey, actualTime)
 }
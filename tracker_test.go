@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// snapshotValues returns the numeric values of all the tracked series by their columns
+func snapshotValues(tr *Tracker) map[string]float64 {
+	values := make(map[string]float64)
+	for _, v := range tr.snapshot() {
+		if value, ok := numericValue(v.Value); ok {
+			values[v.Column] = value
+		}
+	}
+	return values
+}
+
+func TestTrackerRegistry(t *testing.T) {
+	tr := Tracker{}
+	tr.trackOne("custom", 1)
+	tr.register(Metric{Name: "custom", Type: Counter, Help: "Custom"})
+	tr.trackLabeled("custom", Labels{{"cgroup", "/a"}}, 2) // doesn't match the declaration, must be skipped
+	tr.trackOne("other", 3.0)
+
+	values := tr.snapshot()
+	if len(values) != 2 {
+		t.Fatalf("Expected 2 series, got %v", values)
+	}
+	if values[0].Column != "custom" || values[0].Metric.Type != Counter || values[0].Value != 1 {
+		t.Errorf("Series tracked before the declaration must get its metadata, got %v", values[0])
+	}
+	if values[1].Column != "other" || values[1].Metric.Type != Untyped {
+		t.Errorf("Undeclared series must be untyped, got %v", values[1])
+	}
+}